@@ -0,0 +1,57 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// accCodeAppVersionOutdated is the error code the Comfort Cloud API returns when the x-app-version
+// header is older than the minimum version it accepts.
+const accCodeAppVersionOutdated = 4106
+
+// APIError is returned when the Comfort Cloud API responds with an unexpected status code.
+// Code and Message are taken from the error body if the API sent one.
+type APIError struct {
+	Function           string
+	ExpectedStatusCode int
+	StatusCode         int
+	Status             string
+	Code               int
+	Message            string
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("%s: expected status code %d, got %d: %s",
+		e.Function, e.ExpectedStatusCode, e.StatusCode, e.Status)
+	if e.Message != "" {
+		message += fmt.Sprintf(" (code %d: %s)", e.Code, e.Message)
+	}
+	return message
+}
+
+// AppVersionOutdated reports whether the API rejected the request because of the x-app-version header.
+func (e *APIError) AppVersionOutdated() bool {
+	if e.StatusCode < http.StatusBadRequest || e.StatusCode >= http.StatusInternalServerError {
+		return false
+	}
+	return e.Code == accCodeAppVersionOutdated
+}
+
+func newAPIError(function string, expectedStatusCode int, resp *http.Response, body []byte) *APIError {
+	apiError := &APIError{
+		Function:           function,
+		ExpectedStatusCode: expectedStatusCode,
+		StatusCode:         resp.StatusCode,
+		Status:             resp.Status,
+	}
+	var errorBody struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &errorBody) == nil {
+		apiError.Code = errorBody.Code
+		apiError.Message = errorBody.Message
+	}
+	return apiError
+}
@@ -0,0 +1,137 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultAppVersionURL is the App Store lookup of the Comfort Cloud app, which reports its current version.
+const DefaultAppVersionURL = "https://itunes.apple.com/lookup?id=1348640525"
+
+var appVersionPattern = regexp.MustCompile(`^\d+(\.\d+)+$`)
+
+// AppVersionSource provides the current version of the Comfort Cloud app, which is sent as x-app-version.
+type AppVersionSource interface {
+	AppVersion() (string, error)
+}
+
+// URLAppVersionSource reads the app version from a URL returning either an App Store lookup result,
+// a JSON object with a "version" field or the plain version.
+type URLAppVersionSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s URLAppVersionSource) AppVersion() (string, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch app version: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch app version: expected status 200, got %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read app version: %w", err)
+	}
+	return parseAppVersion(body)
+}
+
+// FileAppVersionSource reads the app version from a local file in any of the formats of URLAppVersionSource.
+type FileAppVersionSource struct {
+	FileName string
+}
+
+func (s FileAppVersionSource) AppVersion() (string, error) {
+	data, err := os.ReadFile(s.FileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read app version file: %w", err)
+	}
+	return parseAppVersion(data)
+}
+
+func parseAppVersion(data []byte) (string, error) {
+	var lookup struct {
+		Version string `json:"version"`
+		Results []struct {
+			Version string `json:"version"`
+		} `json:"results"`
+	}
+	version := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &lookup) == nil {
+		version = lookup.Version
+		if len(lookup.Results) > 0 {
+			version = lookup.Results[0].Version
+		}
+	}
+	if !appVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid app version %q", version)
+	}
+	return version, nil
+}
+
+// WithAppVersion sets the x-app-version sent to the API, e.g. a version persisted earlier.
+func WithAppVersion(version string) AuthOption {
+	return func(a *Authentication) {
+		a.appVersion = version
+	}
+}
+
+// WithAppVersionSource sets where the current app version is fetched from when the API reports
+// that x-app-version is outdated.
+func WithAppVersionSource(source AppVersionSource) AuthOption {
+	return func(a *Authentication) {
+		a.appVersionSource = source
+	}
+}
+
+// AppVersion returns the x-app-version currently sent to the API.
+func (a *Authentication) AppVersion() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.appVersion
+}
+
+// updateAppVersion fetches the current app version after the API rejected rejectedVersion. It returns
+// false if no newer version is available. The new version is stored in the token, so it is persisted with it.
+func (a *Authentication) updateAppVersion(rejectedVersion string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.updateAppVersionLocked(rejectedVersion)
+}
+
+// updateAppVersionLocked is updateAppVersion for callers holding a.mu.
+func (a *Authentication) updateAppVersionLocked(rejectedVersion string) (bool, error) {
+	if a.appVersion != rejectedVersion {
+		// Another request has already updated the version
+		return true, nil
+	}
+	if a.appVersionSource == nil {
+		return false, nil
+	}
+	version, err := a.appVersionSource.AppVersion()
+	if err != nil {
+		return false, err
+	}
+	if version == rejectedVersion {
+		return false, nil
+	}
+	slog.Info("Updating app version", "from", rejectedVersion, "to", version)
+	a.appVersion = version
+	if a.token != nil {
+		token := *a.token
+		a.setToken(&token)
+	}
+	return true, nil
+}
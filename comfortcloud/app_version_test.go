@@ -0,0 +1,102 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeAppVersionSource struct {
+	version string
+	calls   int
+}
+
+func (s *fakeAppVersionSource) AppVersion() (string, error) {
+	s.calls++
+	return s.version, nil
+}
+
+// newAccServer is a fake Comfort Cloud API that rejects requests without x-app-version minVersion
+// with the given error body.
+func newAccServer(t *testing.T, minVersion string, errorBody string) (*httptest.Server, *[]string) {
+	var versions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		versions = append(versions, r.Header.Get("x-app-version"))
+		if r.Header.Get("x-app-version") != minVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(errorBody))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{GroupList: []Group{{GroupName: "Home", DeviceList: []Device{
+			{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		}}}})
+	}))
+	t.Cleanup(server.Close)
+	return server, &versions
+}
+
+func newVersionedClient(t *testing.T, server *httptest.Server, source AppVersionSource) (*Client, string) {
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	token := Token{
+		AccessToken:  testJWT(testNow, testNow.Add(time.Hour)),
+		RefreshToken: "refresh",
+		AccClientID:  "acc-client",
+		AppVersion:   "1.0.0",
+	}
+	data, _ := json.Marshal(token)
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("", "", fileName, WithClock(testClock()),
+		WithAuthOptions(WithAccBasePath(server.URL), WithAppVersionSource(source)))
+	if err := client.Login(); err != nil {
+		t.Fatal(err)
+	}
+	return client, fileName
+}
+
+func TestOutdatedAppVersionIsUpdated(t *testing.T) {
+	server, versions := newAccServer(t, "2.0.0", `{"code":4106,"message":"Please update the app"}`)
+	source := &fakeAppVersionSource{version: "2.0.0"}
+	client, fileName := newVersionedClient(t, server, source)
+
+	devices, err := client.GetDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 {
+		t.Errorf("got %d devices, want 1", len(devices))
+	}
+	if source.calls != 1 || len(*versions) != 2 || (*versions)[0] != "1.0.0" || (*versions)[1] != "2.0.0" {
+		t.Errorf("got %d version lookups and requests with versions %v, want a retry with 2.0.0", source.calls, *versions)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Token
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.AppVersion != "2.0.0" {
+		t.Errorf("stored app version %q, want 2.0.0", stored.AppVersion)
+	}
+}
+
+func TestOtherErrorsDoNotUpdateAppVersion(t *testing.T) {
+	server, versions := newAccServer(t, "2.0.0", `{"code":4100,"message":"Invalid version of the request"}`)
+	source := &fakeAppVersionSource{version: "2.0.0"}
+	client, _ := newVersionedClient(t, server, source)
+
+	if _, err := client.GetDevices(); err == nil {
+		t.Fatal("GetDevices succeeded")
+	}
+	if source.calls != 0 || len(*versions) != 1 {
+		t.Errorf("got %d version lookups and %d requests, want no retry", source.calls, len(*versions))
+	}
+}
@@ -0,0 +1,243 @@
+package comfortcloud
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAuditMaxSize  = 10 << 20
+	DefaultAuditMaxFiles = 5
+
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeError   = "error"
+	AuditOutcomeDryRun  = "dry-run"
+)
+
+type callerKey struct{}
+
+// WithCaller returns a context that identifies the caller of control commands in the audit log,
+// e.g. a user name or the name of an automation.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set with WithCaller.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// AuditEntry is one control command in the audit log.
+type AuditEntry struct {
+	Time       time.Time        `json:"time"`
+	Caller     string           `json:"caller,omitempty"`
+	DeviceGuid string           `json:"deviceGuid"`
+	DeviceName string           `json:"deviceName"`
+	Options    ParameterOptions `json:"options"`
+	Previous   *Parameters      `json:"previous,omitempty"`
+	Outcome    string           `json:"outcome"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// AuditLog is an append-only JSON Lines file of control commands. When the file would grow beyond MaxSize
+// it is renamed to <file>.1, older files are shifted to <file>.2 and so on, and files beyond MaxFiles are deleted.
+type AuditLog struct {
+	FileName string
+	MaxSize  int64
+	MaxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewAuditLog(fileName string) *AuditLog {
+	return &AuditLog{FileName: fileName, MaxSize: DefaultAuditMaxSize, MaxFiles: DefaultAuditMaxFiles}
+}
+
+// Append writes an entry to the log.
+func (l *AuditLog) Append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.openLocked(); err != nil {
+		return err
+	}
+	if l.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.MaxSize {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+func (l *AuditLog) openLocked() error {
+	if l.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(l.FileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *AuditLog) rotateLocked() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	l.file = nil
+	if l.MaxFiles > 0 {
+		if err := os.Remove(rotatedAuditFile(l.FileName, l.MaxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+		for i := l.MaxFiles - 1; i >= 1; i-- {
+			err := os.Rename(rotatedAuditFile(l.FileName, i), rotatedAuditFile(l.FileName, i+1))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to rotate audit log: %w", err)
+			}
+		}
+		if err := os.Rename(l.FileName, rotatedAuditFile(l.FileName, 1)); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	} else if err := os.Remove(l.FileName); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	return l.openLocked()
+}
+
+func rotatedAuditFile(fileName string, index int) string {
+	return fmt.Sprintf("%s.%d", fileName, index)
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// AuditQuery selects audit entries. Empty fields and zero times match everything.
+type AuditQuery struct {
+	// Device matches the DeviceGuid or the DeviceName of an entry.
+	Device  string
+	Caller  string
+	Outcome string
+	From    time.Time
+	To      time.Time
+}
+
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	return (q.Device == "" || entry.DeviceGuid == q.Device || entry.DeviceName == q.Device) &&
+		(q.Caller == "" || entry.Caller == q.Caller) &&
+		(q.Outcome == "" || entry.Outcome == q.Outcome) &&
+		(q.From.IsZero() || !entry.Time.Before(q.From)) &&
+		(q.To.IsZero() || entry.Time.Before(q.To))
+}
+
+// QueryAuditLog returns the matching entries of an audit log and its rotated files, oldest first.
+func QueryAuditLog(fileName string, query AuditQuery) ([]AuditEntry, error) {
+	var files []string
+	for i := 1; ; i++ {
+		name := rotatedAuditFile(fileName, i)
+		if _, err := os.Stat(name); err != nil {
+			break
+		}
+		files = append([]string{name}, files...)
+	}
+	files = append(files, fileName)
+
+	var entries []AuditEntry
+	for _, name := range files {
+		file, err := os.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("%s:%d: invalid audit entry: %w", name, line, err)
+			}
+			if query.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+	return entries, nil
+}
+
+// WithAuditLog records every control command of the client in the audit log. The entries include the
+// parameters before the command, which are read with GetDevice: with WithStatusCache they are usually
+// served from the cache, otherwise every command costs an additional status request.
+func WithAuditLog(log *AuditLog) ClientOption {
+	return func(c *Client) {
+		c.audit = log
+	}
+}
+
+// auditSetDevice records a control command. Without an outcome, it is derived from err. Failing to write
+// the audit log is logged but does not fail the command.
+func (c *Client) auditSetDevice(ctx context.Context, device Device, options ParameterOptions, previous *Parameters,
+	outcome string, err error) {
+	if c.audit == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:       c.clock.Now(),
+		Caller:     CallerFromContext(ctx),
+		DeviceGuid: device.DeviceGuid,
+		DeviceName: device.DeviceName,
+		Options:    options,
+		Previous:   previous,
+		Outcome:    outcome,
+	}
+	if err != nil {
+		entry.Outcome = AuditOutcomeError
+		entry.Error = err.Error()
+	} else if entry.Outcome == "" {
+		entry.Outcome = AuditOutcomeSuccess
+	}
+	if err := c.audit.Append(entry); err != nil {
+		slog.Warn("Failed to write audit log", "error", err)
+	}
+}
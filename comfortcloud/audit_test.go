@@ -0,0 +1,211 @@
+package comfortcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditUnresolvedDevice(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := NewAuditLog(fileName)
+	defer log.Close()
+	client := newListedClient(Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	WithAuditLog(log)(client)
+
+	ctx := WithCaller(context.Background(), "test")
+	if err := client.SetDeviceContext(ctx, "Kitchen", WithPower(PowerOn)); err == nil {
+		t.Fatal("SetDevice of an unknown device succeeded")
+	}
+
+	entries, err := QueryAuditLog(fileName, AuditQuery{Device: "Kitchen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.Outcome != AuditOutcomeError || entry.Error == "" || entry.Caller != "test" || entry.Options.Operate == nil {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "audit.jsonl")
+	entry := func(i int) AuditEntry {
+		return AuditEntry{Time: testNow.Add(time.Duration(i) * time.Minute), DeviceGuid: "guid-1",
+			DeviceName: fmt.Sprintf("entry %d", i), Outcome: AuditOutcomeSuccess}
+	}
+	line, _ := json.Marshal(entry(0))
+	log := NewAuditLog(fileName)
+	// Two entries fit into a file, the current file and two rotated files are kept.
+	log.MaxSize = int64(2*len(line) + 2)
+	log.MaxFiles = 2
+	for i := 0; i < 9; i++ {
+		if err := log.Append(entry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{fileName, fileName + ".1", fileName + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > log.MaxSize {
+			t.Errorf("%s has %d bytes, more than MaxSize %d", name, info.Size(), log.MaxSize)
+		}
+	}
+	if _, err := os.Stat(fileName + ".3"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s.3 was kept: %v", fileName, err)
+	}
+
+	// Entries 0 to 3 were rotated out, the query reads the rotated files oldest first.
+	entries, err := QueryAuditLog(fileName, AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.DeviceName)
+	}
+	if want := "entry 4,entry 5,entry 6,entry 7,entry 8"; strings.Join(names, ",") != want {
+		t.Errorf("got entries %v, want %s", names, want)
+	}
+
+	// A reopened log continues with the size of the existing file, which already holds entry 8.
+	log = NewAuditLog(fileName)
+	log.MaxSize, log.MaxFiles = int64(2*len(line)+2), 2
+	for i := 9; i < 11; i++ {
+		if err := log.Append(entry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log.Close()
+	entries, err = QueryAuditLog(fileName, AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, entry := range entries {
+		names = append(names, entry.DeviceName)
+	}
+	if want := "entry 6,entry 7,entry 8,entry 9,entry 10"; strings.Join(names, ",") != want {
+		t.Errorf("got entries %v after reopening, want %s", names, want)
+	}
+}
+
+func TestQueryAuditLog(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "audit.jsonl")
+	log := NewAuditLog(fileName)
+	log.MaxSize = 1
+	entries := []AuditEntry{
+		{Time: testNow, Caller: "alice", DeviceGuid: "guid-1", DeviceName: "Living room", Outcome: AuditOutcomeSuccess},
+		{Time: testNow.Add(time.Hour), Caller: "thermostat", DeviceGuid: "guid-2", DeviceName: "Bedroom",
+			Outcome: AuditOutcomeError, Error: "offline"},
+		{Time: testNow.Add(2 * time.Hour), Caller: "alice", DeviceGuid: "guid-2", DeviceName: "Bedroom",
+			Outcome: AuditOutcomeDryRun},
+	}
+	// With a MaxSize of one byte, every entry ends up in its own file.
+	for _, entry := range entries {
+		if err := log.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log.Close()
+
+	tests := []struct {
+		name  string
+		query AuditQuery
+		want  []int
+	}{
+		{name: "all", want: []int{0, 1, 2}},
+		{name: "by guid", query: AuditQuery{Device: "guid-2"}, want: []int{1, 2}},
+		{name: "by name", query: AuditQuery{Device: "Living room"}, want: []int{0}},
+		{name: "by caller", query: AuditQuery{Caller: "alice"}, want: []int{0, 2}},
+		{name: "by outcome", query: AuditQuery{Outcome: AuditOutcomeError}, want: []int{1}},
+		{name: "from", query: AuditQuery{From: testNow.Add(time.Hour)}, want: []int{1, 2}},
+		{name: "to is exclusive", query: AuditQuery{To: testNow.Add(time.Hour)}, want: []int{0}},
+		{name: "combined", query: AuditQuery{Device: "Bedroom", Caller: "alice"}, want: []int{2}},
+		{name: "none", query: AuditQuery{Device: "Kitchen"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := QueryAuditLog(fileName, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d entries, want %d", len(got), len(test.want))
+			}
+			for i, index := range test.want {
+				if !reflect.DeepEqual(got[i], entries[index]) {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], entries[index])
+				}
+			}
+		})
+	}
+
+	if entries, err := QueryAuditLog(filepath.Join(t.TempDir(), "missing.jsonl"), AuditQuery{}); err != nil ||
+		len(entries) != 0 {
+		t.Errorf("missing log: got %v, %v", entries, err)
+	}
+	if err := os.WriteFile(fileName, []byte("not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := QueryAuditLog(fileName, AuditQuery{}); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("invalid entry: got %v, want an error with the line number", err)
+	}
+}
+
+func TestAuditPreviousState(t *testing.T) {
+	tests := []struct {
+		name           string
+		options        []ClientOption
+		statusRequests int
+	}{
+		{name: "without status cache", statusRequests: 2},
+		{name: "with status cache", options: []ClientOption{WithStatusCache(time.Minute, 0)}, statusRequests: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room",
+				Parameters: Parameters{Operate: PowerOff}})
+			fileName := filepath.Join(t.TempDir(), "audit.jsonl")
+			log := NewAuditLog(fileName)
+			defer log.Close()
+			client := newFakeAccClient(t, server, append(test.options, WithAuditLog(log))...)
+
+			if _, err := client.GetDevice("guid-1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.SetDevice("guid-1", WithPower(PowerOn)); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.statusRequestCount("guid-1"); got != test.statusRequests {
+				t.Errorf("got %d status requests, want %d", got, test.statusRequests)
+			}
+
+			entries, err := QueryAuditLog(fileName, AuditQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Previous == nil || entries[0].Previous.Operate != PowerOff ||
+				entries[0].Outcome != AuditOutcomeSuccess || !entries[0].Time.Equal(testNow) {
+				t.Errorf("unexpected audit entries %+v", entries)
+			}
+		})
+	}
+}
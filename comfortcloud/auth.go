@@ -16,27 +16,107 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Authentication struct {
-	username   string
-	password   string
-	token      *Token
-	raw        bool
-	appVersion string
+	mu               sync.Mutex
+	username         string
+	password         string
+	token            *Token
+	raw              bool
+	appVersion       string
+	appVersionSource AppVersionSource
+	challengeHandler ChallengeHandler
+	tokenListener    func(*Token)
+	limiter          *rateLimiter
+	leeway           time.Duration
+	authBasePath     string
+	accBasePath      string
+	clock            Clock
+	signer           Signer
+	signerName       string
 }
 
-func NewAuthentication(username, password string, token *Token) *Authentication {
-	return &Authentication{
+// DefaultTokenLeeway is how long before its expiry an access token is refreshed, to allow for clock skew.
+const DefaultTokenLeeway = 30 * time.Second
+
+type AuthOption func(*Authentication)
+
+// WithChallengeHandler sets the handler that is asked to answer MFA, consent and terms of service
+// pages during login.
+func WithChallengeHandler(handler ChallengeHandler) AuthOption {
+	return func(a *Authentication) {
+		a.challengeHandler = handler
+	}
+}
+
+// WithTokenLeeway sets how long before its expiry an access token is considered expired.
+func WithTokenLeeway(leeway time.Duration) AuthOption {
+	return func(a *Authentication) {
+		a.leeway = leeway
+	}
+}
+
+// WithAuthBasePath replaces the Panasonic ID base URL used to log in and to refresh and revoke tokens,
+// e.g. to use a local fake Panasonic ID.
+func WithAuthBasePath(basePath string) AuthOption {
+	return func(a *Authentication) {
+		a.authBasePath = basePath
+	}
+}
+
+// WithAccBasePath replaces the Comfort Cloud API base URL, e.g. to use a local fake API.
+func WithAccBasePath(basePath string) AuthOption {
+	return func(a *Authentication) {
+		a.accBasePath = basePath
+	}
+}
+
+func NewAuthentication(username, password string, token *Token, options ...AuthOption) *Authentication {
+	a := &Authentication{
 		username:   username,
 		password:   password,
 		token:      token,
 		appVersion: XAppVersion,
+
+		appVersionSource: URLAppVersionSource{URL: DefaultAppVersionURL},
+		leeway:           DefaultTokenLeeway,
+		authBasePath:     BasePathAuth,
+		accBasePath:      BasePathAcc,
+		clock:            systemClock{},
+	}
+	if token != nil && token.AppVersion != "" {
+		a.appVersion = token.AppVersion
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// setToken replaces the token, records the app version it is used with and notifies the token listener.
+func (a *Authentication) setToken(token *Token) {
+	token.AppVersion = a.appVersion
+	a.token = token
+	if a.tokenListener != nil {
+		a.tokenListener(token)
 	}
 }
 
+// GetNewToken logs in with the username and password and replaces the token.
 func (a *Authentication) GetNewToken() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.getNewToken()
+}
+
+// getNewToken implements GetNewToken. The caller holds a.mu.
+func (a *Authentication) getNewToken() error {
+	if a.username == "" || a.password == "" {
+		return ErrLoginRequired
+	}
 	slog.Info("Starting token retrieval")
 	jar, _ := cookiejar.New(nil)
 	client := &http.Client{
@@ -51,7 +131,7 @@ func (a *Authentication) GetNewToken() error {
 
 	// Step 1: Authorize
 
-	resp, err := makeAuthorizeRequest(codeChallenge, state, client)
+	resp, err := a.makeAuthorizeRequest(codeChallenge, state, client)
 	if err != nil {
 		slog.Error("Authorization request failed", "error", err)
 		return err
@@ -69,7 +149,7 @@ func (a *Authentication) GetNewToken() error {
 	}
 
 	if !strings.HasPrefix(location, RedirectUri) {
-		req, _ := http.NewRequest("GET", BasePathAuth+location, nil)
+		req, _ := http.NewRequest("GET", a.authBasePath+location, nil)
 		req.Header.Set("User-Agent", "okhttp/4.10.0")
 		resp, err = client.Do(req)
 		if err != nil {
@@ -97,72 +177,80 @@ func (a *Authentication) GetNewToken() error {
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("login: expected status 200, got %d", resp.StatusCode)
+		if err := checkLoginFormResponse(resp); err != nil {
+			return err
 		}
 
-		resp, err = performLoginCallback(resp, client)
+		location, err = a.performLoginCallback(resp, client)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Step 5: Get Token
-	location = resp.Header.Get("Location")
-	tokenResponse, err := getToken(location, codeVerifier, client)
+	tokenResponse, err := a.getToken(location, codeVerifier, client)
 	if err != nil {
 		return fmt.Errorf("failed to get token: %w", err)
 	}
 
-	accessToken := tokenResponse.AccessToken
+	return a.loginToAcc(tokenResponse, client)
+}
 
-	now := time.Unix(tokenResponse.AccessTokenIssuedAt, 0).UTC()
+// loginToAcc registers the OAuth token with the Comfort Cloud API and stores it with the ACC client ID.
+// The caller holds a.mu.
+func (a *Authentication) loginToAcc(tokenResponse Token, client *http.Client) error {
+	postUrl := a.accBasePath + "/auth/v2/login"
+	reqBody := []byte(`{"language": 0}`)
+
+	for attempt := 0; ; attempt++ {
+		input := SigningInput{
+			Time:        time.Unix(tokenResponse.AccessTokenIssuedAt, 0).UTC(),
+			AccessToken: tokenResponse.AccessToken,
+			AppVersion:  a.appVersion,
+		}
+		req, err := a.newAPIRequest(http.MethodPost, postUrl, reqBody, input)
+		if err != nil {
+			return err
+		}
 
-	apiKey := tokenResponse.getAPIKey(now)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %w", err)
+		}
 
-	postUrl := BasePathAcc + "/auth/v2/login"
-	timestamp := now.Format("2006-01-02 15:04:05")
-	reqBody := `{"language": 0}`
-	req, _ := http.NewRequest("POST", postUrl, strings.NewReader(reqBody))
-	//req, _ = http.NewRequest("POST", "http://localhost:8080", strings.NewReader(reqBody))
-	req.Header.Set("User-Agent", "G-RAC")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Content-Type", "application/json;charset=utf-8")
-	req.Header.Set("x-app-name", "Comfort Cloud")
-	req.Header.Set("x-app-timestamp", timestamp)
-	req.Header.Set("x-app-type", "1")
-	req.Header.Set("x-app-version", XAppVersion)
-	req.Header.Set("x-cfc-api-key", apiKey)
-	req.Header.Set("x-user-authorization-v2", "Bearer "+accessToken)
+		if resp.StatusCode != http.StatusOK {
+			apiError := newAPIError("get_acc_client_id", http.StatusOK, resp, body)
+			if attempt == 0 && apiError.AppVersionOutdated() {
+				if updated, err := a.updateAppVersionLocked(input.AppVersion); updated {
+					continue
+				} else if err != nil {
+					return fmt.Errorf("%w, failed to update app version: %w", apiError, err)
+				}
+			}
+			return apiError
+		}
 
-	resp, err = client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+		// Extract ACC Client ID
+		accClientID, err := parseAccClientIDResponse(body)
+		if err != nil {
+			return err
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("get_acc_client_id: expected status 200, got %d", resp.StatusCode)
-	}
+		token := tokenResponse
+		token.AccClientID = accClientID
+		a.setToken(&token)
 
-	// Extract ACC Client ID
-	var accClientResponse map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&accClientResponse)
-	if err != nil {
-		return err
+		return nil
 	}
-	accClientID := accClientResponse["clientId"].(string)
-
-	token := tokenResponse
-	token.AccClientID = accClientID
-	a.token = &token
-
-	return nil
 }
 
-func getToken(location string, codeVerifier string, client *http.Client) (Token, error) {
+// getToken exchanges the authorization code in the redirect location for a token.
+func (a *Authentication) getToken(location string, codeVerifier string, client *http.Client) (Token, error) {
 	parsedURL, err := url.Parse(location)
 	if err != nil {
 		return Token{}, fmt.Errorf("failed to parse redirect URL: %w", err)
@@ -173,14 +261,14 @@ func getToken(location string, codeVerifier string, client *http.Client) (Token,
 	tokenRequest := map[string]string{
 		"scope":         "openid",
 		"client_id":     AppClientId,
-		"grant_type":    "authorization_code",
+		"grant_type":    grantTypeAuthorizationCode,
 		"code":          code,
 		"redirect_uri":  RedirectUri,
 		"code_verifier": codeVerifier,
 	}
 
 	jsonData, _ := json.Marshal(tokenRequest)
-	req, _ := http.NewRequest("POST", BasePathAuth+"/oauth/token", strings.NewReader(string(jsonData)))
+	req, _ := http.NewRequest("POST", a.authBasePath+"/oauth/token", strings.NewReader(string(jsonData)))
 	req.Header.Set("Auth0-Client", Auth0Client)
 	req.Header.Set("User-Agent", "okhttp/4.10.0")
 	req.Header.Set("Content-Type", "application/json")
@@ -190,42 +278,58 @@ func getToken(location string, codeVerifier string, client *http.Client) (Token,
 		return Token{}, err
 	}
 	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to read response body: %w", err)
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return Token{}, fmt.Errorf("get_token: expected status 200, got %d", resp.StatusCode)
+		return Token{}, parseAuth0Error("get_token", resp.StatusCode, body)
 	}
 
 	// Parse token response
-	var tokenResponse Token
-	err = json.NewDecoder(resp.Body).Decode(&tokenResponse)
+	tokenResponse, err := parseTokenResponse(body, grantTypeAuthorizationCode)
 	if err != nil {
-		return Token{}, fmt.Errorf("failed to decode token response: %w", err)
+		return Token{}, err
 	}
-	fmt.Println("Token Response:", tokenResponse)
 
 	// Step 6: Get ACC Client ID
-	if !tokenResponse.isValid() {
+	if !tokenResponse.isValidAt(a.clock.Now(), a.leeway) {
 		return Token{}, errors.New("invalid token response")
 	}
 	return tokenResponse, nil
 }
 
+// RefreshToken exchanges the refresh token for a new access token. The expiry is taken from the new
+// access token, or computed from expires_in if it cannot be decoded. If the response contains a new
+// refresh token (refresh token rotation), it replaces the old one.
 func (a *Authentication) RefreshToken() error {
-	//def _refresh_token(self):
-	//# do before, so that timestamp is older rather than newer
-	//now = datetime.datetime.now()
-	//unix_time_token_received = time.mktime(now.timetuple())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.refreshToken()
+}
+
+// refreshToken implements RefreshToken. The caller holds a.mu.
+func (a *Authentication) refreshToken() error {
+	if a.token == nil || a.token.RefreshToken == "" {
+		return errors.New("no refresh token available")
+	}
+
+	scope := a.token.Scope
+	if scope == "" {
+		scope = OAuthScopes
+	}
 
 	// Prepare the request payload
 	payload := map[string]interface{}{
-		"scope":         a.token.Scope,
+		"scope":         scope,
 		"client_id":     AppClientId,
 		"refresh_token": a.token.RefreshToken,
-		"grant_type":    "refresh_token",
+		"grant_type":    grantTypeRefreshToken,
 	}
 
 	// Prepare the request URL
-	tokenUrl := fmt.Sprintf("%s/oauth/token", BasePathAuth)
+	tokenUrl := fmt.Sprintf("%s/oauth/token", a.authBasePath)
 	jsonBytes, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal JSON data: %w", err)
@@ -236,54 +340,82 @@ func (a *Authentication) RefreshToken() error {
 	if err != nil {
 		return fmt.Errorf("failed to create POST request: %v", err)
 	}
+	req.Header.Set("Auth0-Client", Auth0Client)
+	req.Header.Set("User-Agent", "okhttp/4.10.0")
+	req.Header.Set("Content-Type", "application/json")
 
+	// Take the time before the request, so that the computed expiry is earlier rather than later
+	requestedAt := a.clock.Now()
 	client := &http.Client{}
-	resp, err := client.Do(req)
-
-	if err != nil || resp.StatusCode != http.StatusOK {
-		err := a.GetNewToken()
-		if err != nil {
-			return fmt.Errorf("failed to get new token: %w", err)
+	tokenResponse, err := requestRefreshedToken(client, req)
+	if err != nil {
+		newTokenErr := a.getNewToken()
+		if newTokenErr != nil {
+			return fmt.Errorf("failed to refresh token: %w, failed to get new token: %w", err, newTokenErr)
 		}
 		return nil
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-	// Parse the response
-	var tokenResponse map[string]interface{}
-	if err := json.Unmarshal(body, &tokenResponse); err != nil {
-		return fmt.Errorf("failed to parse token response: %v", err)
-	}
-	iat, exp, err := extractIATAndEXPFromJWT(a.token.AccessToken)
+	iat, exp, err := extractIATAndEXPFromJWT(tokenResponse.AccessToken)
 	if err != nil {
-		return fmt.Errorf("failed to extract IAT: %w", err)
+		if tokenResponse.ExpiresInSec <= 0 {
+			return fmt.Errorf("failed to determine token expiry: %w", err)
+		}
+		iat = requestedAt.Unix()
+		exp = iat + int64(tokenResponse.ExpiresInSec)
 	}
-	// Update the token
-	a.token = &Token{
-		AccessToken:          tokenResponse["access_token"].(string),
-		RefreshToken:         tokenResponse["refresh_token"].(string),
-		IDToken:              tokenResponse["id_token"].(string),
+
+	refreshed := Token{
+		AccessToken:          tokenResponse.AccessToken,
+		RefreshToken:         tokenResponse.RefreshToken,
+		IDToken:              tokenResponse.IDToken,
 		AccessTokenIssuedAt:  iat,
 		AccessTokenExpiresAt: exp,
-		ExpiresInSec:         int(tokenResponse["expires_in"].(float64)),
+		ExpiresInSec:         tokenResponse.ExpiresInSec,
 		AccClientID:          a.token.AccClientID,
-		Scope:                tokenResponse["scope"].(string),
+		Scope:                tokenResponse.Scope,
+	}
+	// Without rotation, the refresh token and the other fields stay valid
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = a.token.RefreshToken
+	}
+	if refreshed.IDToken == "" {
+		refreshed.IDToken = a.token.IDToken
 	}
+	if refreshed.Scope == "" {
+		refreshed.Scope = scope
+	}
+
+	// Update the token
+	a.setToken(&refreshed)
 
 	return nil
 }
 
-func performLoginCallback(resp *http.Response, client *http.Client) (*http.Response, error) {
+func requestRefreshedToken(client *http.Client, req *http.Request) (Token, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("refresh request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, parseAuth0Error("refresh_token", resp.StatusCode, body)
+	}
+	// Parse the response
+	return parseTokenResponse(body, grantTypeRefreshToken)
+}
+
+func (a *Authentication) performLoginCallback(resp *http.Response, client *http.Client) (string, error) {
 	// Step 4: Extract login callback parameters
 	bodyBytes, _ := io.ReadAll(resp.Body)
 	bodyStr := string(bodyBytes)
 	hiddenInputs, err := ExtractHiddenInputValues(bodyStr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract hidden input values: %v", err)
+		return "", fmt.Errorf("failed to extract hidden input values: %v", err)
 	}
 
 	formData := url.Values{}
@@ -295,35 +427,20 @@ func performLoginCallback(resp *http.Response, client *http.Client) (*http.Respo
 	userAgent := "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 " +
 		"(KHTML, like Gecko) Chrome/113.0.0.0 Mobile Safari/537.36"
 
-	req, err := http.NewRequest("POST", BasePathAuth+"/login/callback", strings.NewReader(formData.Encode()))
+	req, err := http.NewRequest("POST", a.authBasePath+"/login/callback", strings.NewReader(formData.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("error creating POST request: %w", err)
+		return "", fmt.Errorf("error creating POST request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", userAgent)
 
 	resp, err = client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making POST request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusFound { // 302 Found
-		return nil, fmt.Errorf("Unexpected status code: %d\n", resp.StatusCode)
+		return "", fmt.Errorf("error making POST request: %w", err)
 	}
-	// Follow redirect
-	location := resp.Header.Get("Location")
 
-	req, _ = http.NewRequest("GET", BasePathAuth+location, nil)
-	req.Header.Set("User-Agent", "okhttp/4.10.0")
-	resp, err = client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making POST request: %w", err)
-	}
-	if resp.StatusCode != http.StatusFound {
-		return nil, fmt.Errorf("Unexpected status code: %d\n", resp.StatusCode)
-	}
-	return resp, nil
+	// Follow the redirects, answering MFA, consent or terms of service pages on the way
+	return a.followLoginRedirects(resp, client)
 }
 
 func (a *Authentication) submitLoginForm(csrf string, state string, client *http.Client) (*http.Response, error) {
@@ -344,7 +461,7 @@ func (a *Authentication) submitLoginForm(csrf string, state string, client *http
 	}
 
 	jsonData, _ := json.Marshal(loginData)
-	req, _ := http.NewRequest("POST", BasePathAuth+"/usernamepassword/login", bytes.NewReader(jsonData))
+	req, _ := http.NewRequest("POST", a.authBasePath+"/usernamepassword/login", bytes.NewReader(jsonData))
 	req.Header.Set("Auth0-Client", Auth0Client)
 	req.Header.Set("User-Agent", "okhttp/4.10.0")
 	req.Header.Set("Content-Type", "application/json")
@@ -371,7 +488,21 @@ func handleRedirect(resp *http.Response, state string) (string, string, error) {
 	return location, state, nil
 }
 
-func makeAuthorizeRequest(codeChallenge string, state string, client *http.Client) (*http.Response, error) {
+func (a *Authentication) makeAuthorizeRequest(codeChallenge string, state string, client *http.Client) (*http.Response, error) {
+	req, err := http.NewRequest("GET", authorizeURL(a.authBasePath, codeChallenge, state), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building authorize request %w", err)
+	}
+	req.Header.Set("User-Agent", "okhttp/4.10.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making authorization request %w", err)
+	}
+	return resp, nil
+}
+
+// authorizeURL returns the URL of the authorize endpoint of the Panasonic ID at basePath.
+func authorizeURL(basePath string, codeChallenge string, state string) string {
 	params := url.Values{
 		"scope":                 {OAuthScopes},
 		"audience":              {OAuthAudience},
@@ -384,17 +515,7 @@ func makeAuthorizeRequest(codeChallenge string, state string, client *http.Clien
 		"redirect_uri":          {RedirectUri},
 		"state":                 {state},
 	}
-
-	req, err := http.NewRequest("GET", BasePathAuth+"/authorize?"+params.Encode(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("error building authorize request %w", err)
-	}
-	req.Header.Set("User-Agent", "okhttp/4.10.0")
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making authorization request %w", err)
-	}
-	return resp, nil
+	return basePath + "/authorize?" + params.Encode()
 }
 
 func generateOAuthParameters() (string, string, string) {
@@ -439,142 +560,153 @@ func ExtractHiddenInputValues(htmlBody string) (map[string]string, error) {
 }
 
 func (a *Authentication) ExecuteGet(url, functionDescription string, expectedStatusCode int) ([]byte, error) {
+	return a.execute(http.MethodGet, url, nil, functionDescription, expectedStatusCode)
+}
 
-	if !a.token.isValid() {
-		err := a.GetNewToken()
+func (a *Authentication) ExecutePost(url string, jsonData map[string]interface{}, functionDescription string, expectedStatusCode int) ([]byte, error) {
+	// Convert JSON data to bytes
+	jsonBytes, err := json.Marshal(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
+	}
+	return a.execute(http.MethodPost, url, jsonBytes, functionDescription, expectedStatusCode)
+}
+
+// execute sends an API request. If the API reports that the app version is outdated, the current
+// version is fetched from the app version source and the request is retried once.
+func (a *Authentication) execute(method, url string, body []byte, functionDescription string, expectedStatusCode int) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		// Ensure the token is valid
+		input, err := a.validSigningInput()
 		if err != nil {
-			return nil, fmt.Errorf("invalid or expired token. error getting new token: %w", err)
+			return nil, err
 		}
-	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GET request: %v", err)
-	}
+		req, err := a.newAPIRequest(method, url, body, input)
+		if err != nil {
+			return nil, err
+		}
 
-	// Add headers for the API call
-	headers := a.getHeaderForAPICalls()
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
+		// Send the request
+		a.limiter.wait(a.clock)
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %v", err)
+		}
+		responseBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %v", err)
+		}
 
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
+		// Check the response status code
+		if resp.StatusCode != expectedStatusCode {
+			apiError := newAPIError(functionDescription, expectedStatusCode, resp, responseBody)
+			if attempt == 0 && apiError.AppVersionOutdated() {
+				if updated, err := a.updateAppVersion(input.AppVersion); updated {
+					continue
+				} else if err != nil {
+					return nil, fmt.Errorf("%w, failed to update app version: %w", apiError, err)
+				}
+			}
+			return nil, apiError
+		}
 
-	// Check the response status code
-	if resp.StatusCode != expectedStatusCode {
-		return nil, fmt.Errorf(
-			"%s: expected status code %d, got %d: %s",
-			functionDescription,
-			expectedStatusCode,
-			resp.StatusCode,
-			resp.Status,
-		)
+		return responseBody, nil
 	}
+}
 
-	// Read and return the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
+// hasValidToken reports whether the token is valid and does not expire within the leeway.
+func (a *Authentication) hasValidToken() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token.isValidAt(a.clock.Now(), a.leeway)
+}
 
-	return body, nil
+// currentToken returns the token, which is replaced rather than modified when it changes.
+func (a *Authentication) currentToken() *Token {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token
 }
 
-func (a *Authentication) ExecutePost(url string, jsonData map[string]interface{}, functionDescription string, expectedStatusCode int) ([]byte, error) {
-	// Ensure the token is valid
-	if !a.token.isValid() {
-		err := a.GetNewToken()
-		if err != nil {
-			return nil, fmt.Errorf("invalid or expired token. error getting new token: %w", err)
-		}
-	}
+// validSigningInput refreshes the token if necessary and returns the input for signing an API call.
+func (a *Authentication) validSigningInput() (SigningInput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// Convert JSON data to bytes
-	jsonBytes, err := json.Marshal(jsonData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
+	if err := a.login(); err != nil {
+		return SigningInput{}, err
 	}
+	return a.signingInput(a.token), nil
+}
 
-	// Create the HTTP POST request
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create POST request: %v", err)
+// signingInput returns the input for signing an API call with token. The caller holds a.mu.
+func (a *Authentication) signingInput(token *Token) SigningInput {
+	return SigningInput{
+		Time:        a.clock.Now(),
+		AccessToken: token.AccessToken,
+		AccClientID: token.AccClientID,
+		AppVersion:  a.appVersion,
 	}
+}
 
-	// Add headers for the API call
-	headers := a.getHeaderForAPICalls()
-	for key, value := range headers {
-		req.Header.Set(key, value)
+// newAPIRequest builds a Comfort Cloud API request with the common headers and the headers of the signer.
+// All API requests are built here.
+func (a *Authentication) newAPIRequest(method, url string, body []byte, input SigningInput) (*http.Request, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
 	}
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	req, err := http.NewRequest(method, url, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check the response status code
-	if resp.StatusCode != expectedStatusCode {
-		return nil, fmt.Errorf(
-			"%s: expected status code %d, got %d: %s",
-			functionDescription,
-			expectedStatusCode,
-			resp.StatusCode,
-			resp.Status,
-		)
+		return nil, fmt.Errorf("failed to create %s request: %v", method, err)
 	}
 
-	// Read and return the response body
-	body, err := io.ReadAll(resp.Body)
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	req.Header.Set("User-Agent", "G-RAC")
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Connection", "keep-alive")
+	signer, err := a.requestSigner(input.AppVersion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return nil, err
 	}
-
-	return body, nil
-}
-
-func (a *Authentication) getHeaderForAPICalls() map[string]string {
-	now := time.Now()
-
-	headers := map[string]string{
-		"Content-Type":            "application/json;charset=utf-8",
-		"x-app-name":              "Comfort Cloud",
-		"user-agent":              "G-RAC",
-		"x-app-timestamp":         now.Format("2006-01-02 15:04:05"),
-		"x-app-type":              "1",
-		"x-app-version":           a.appVersion,
-		"x-cfc-api-key":           a.token.getAPIKey(now),
-		"x-client-id":             a.token.AccClientID,
-		"x-user-authorization-v2": "Bearer " + a.token.AccessToken,
-		"Accept-Encoding":         "gzip, deflate",
-		"Accept":                  "*/*",
-		"Connection":              "keep-alive",
+	for key, value := range signer.Headers(input) {
+		req.Header.Set(key, value)
 	}
-
-	return headers
+	return req, nil
 }
 
 func (a *Authentication) Login() error {
-	if !a.token.isValid() {
-		expired, err := a.token.isAccessTokenExpired()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.login()
+}
+
+// login refreshes or replaces the token if it is not valid. The caller holds a.mu.
+func (a *Authentication) login() error {
+	now := a.clock.Now()
+	if !a.token.isValidAt(now, a.leeway) {
+		// A token loaded from a file that only keeps the refresh token has no access token yet
+		if a.token != nil && a.token.AccessToken == "" && a.token.RefreshToken != "" {
+			if err := a.refreshToken(); err != nil {
+				return fmt.Errorf("failed to refresh token: %w", err)
+			}
+			return nil
+		}
+		expired, err := a.token.isAccessTokenExpiredAt(now, a.leeway)
 		if err != nil {
-			err := a.GetNewToken()
+			err := a.getNewToken()
 			if err != nil {
 				return fmt.Errorf("invalid or expired token. error getting new token: %w", err)
 			}
 		}
 		if expired {
-			err := a.RefreshToken()
+			err := a.refreshToken()
 			if err != nil {
-				err := a.GetNewToken()
+				err := a.getNewToken()
 				if err != nil {
 					return fmt.Errorf("invalid or expired token. error getting new token: %w", err)
 				}
@@ -584,28 +716,104 @@ func (a *Authentication) Login() error {
 	return nil
 }
 
-// Logout logs out of the API.
+// Logout logs out of the Comfort Cloud API, revokes the refresh token and clears the token and credentials
+// held in memory. The local state is cleared even if a remote step fails; the returned error joins the
+// failures of the remote steps.
 func (a *Authentication) Logout() error {
+	a.mu.Lock()
+	token := a.token
+	a.token = nil
+	a.username = ""
+	a.password = ""
+	a.mu.Unlock()
+
+	if token == nil {
+		return nil
+	}
+
+	var errs []error
+	if token.isValidAt(a.clock.Now(), 0) {
+		if err := a.accLogout(token); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if token.RefreshToken != "" {
+		if err := a.revokeRefreshToken(token.RefreshToken); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// accLogout logs the token out of the Comfort Cloud API.
+func (a *Authentication) accLogout(token *Token) error {
 	// Prepare the URL for the logout request
-	logoutUrl := fmt.Sprintf("%s/auth/v2/logout", BasePathAcc)
+	logoutUrl := fmt.Sprintf("%s/auth/v2/logout", a.accBasePath)
+
+	a.mu.Lock()
+	input := a.signingInput(token)
+	a.mu.Unlock()
+	req, err := a.newAPIRequest(http.MethodPost, logoutUrl, []byte("{}"), input)
+	if err != nil {
+		return err
+	}
 
 	// Send the POST request
-	response, err := a.ExecutePost(logoutUrl, nil, "logout", http.StatusOK)
+	a.limiter.wait(a.clock)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("logout request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	response, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("logout request failed: %v", err)
+		return fmt.Errorf("failed to read logout response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError("logout", http.StatusOK, resp, response)
 	}
 
 	// Parse the response
-	var result map[string]interface{}
+	var result struct {
+		Result *int `json:"result"`
+	}
 	if err := json.Unmarshal(response, &result); err != nil {
-		return fmt.Errorf("failed to parse logout response: %v", err)
+		return fmt.Errorf("failed to parse logout response: %w", err)
 	}
 
 	// Check if the logout was successful
-	if result["result"].(float64) != 0 {
+	if result.Result != nil && *result.Result != 0 {
 		// Logout failed, but we don't raise an error (as per the Python implementation)
-		fmt.Println("Logout issue detected, but ignoring it")
+		slog.Warn("Logout issue detected, but ignoring it", "result", *result.Result)
 	}
 
 	return nil
 }
+
+// revokeRefreshToken revokes the refresh token at the Auth0 revocation endpoint.
+func (a *Authentication) revokeRefreshToken(refreshToken string) error {
+	payload, err := json.Marshal(map[string]string{
+		"client_id": AppClientId,
+		"token":     refreshToken,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON data: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, a.authBasePath+"/oauth/revoke", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create revoke request: %w", err)
+	}
+	req.Header.Set("Auth0-Client", Auth0Client)
+	req.Header.Set("User-Agent", "okhttp/4.10.0")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("revoke request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revoke_token: expected status 200, got %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package comfortcloud
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+func testClock() Clock {
+	return ClockFunc(func() time.Time { return testNow })
+}
+
+// testJWT returns an unsigned JWT with the given issue and expiry times.
+func testJWT(iat, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d,"exp":%d}`, iat.Unix(), exp.Unix())))
+	return header + "." + payload + ".sig"
+}
+
+// tokenServer is a fake Panasonic ID token endpoint that answers refresh requests with response.
+type tokenServer struct {
+	*httptest.Server
+	requests []*http.Request
+	bodies   []map[string]string
+}
+
+func newTokenServer(t *testing.T, response map[string]any) *tokenServer {
+	s := &tokenServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/oauth/token" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid refresh request: %v", err)
+		}
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func expiredToken() *Token {
+	return &Token{
+		AccessToken:          testJWT(testNow.Add(-2*time.Hour), testNow.Add(-time.Hour)),
+		AccessTokenIssuedAt:  testNow.Add(-2 * time.Hour).Unix(),
+		AccessTokenExpiresAt: testNow.Add(-time.Hour).Unix(),
+		RefreshToken:         "refresh-old",
+		IDToken:              "id-old",
+		AccClientID:          "acc-client",
+		Scope:                "openid offline_access",
+	}
+}
+
+func TestRefreshTokenExpiryFromJWT(t *testing.T) {
+	exp := testNow.Add(24 * time.Hour)
+	server := newTokenServer(t, map[string]any{
+		"access_token": testJWT(testNow, exp),
+		"expires_in":   60,
+	})
+	auth := NewAuthentication("", "", expiredToken(), WithAuthBasePath(server.URL), WithAuthClock(testClock()))
+
+	if err := auth.RefreshToken(); err != nil {
+		t.Fatal(err)
+	}
+	token := auth.currentToken()
+	if token.AccessTokenIssuedAt != testNow.Unix() || token.AccessTokenExpiresAt != exp.Unix() {
+		t.Errorf("iat, exp = %d, %d, want %d, %d", token.AccessTokenIssuedAt, token.AccessTokenExpiresAt,
+			testNow.Unix(), exp.Unix())
+	}
+	if token.AccClientID != "acc-client" || token.IDToken != "id-old" || token.Scope != "openid offline_access" {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	if len(server.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(server.requests))
+	}
+	req, body := server.requests[0], server.bodies[0]
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %s", got)
+	}
+	if got := req.Header.Get("Auth0-Client"); got != Auth0Client {
+		t.Errorf("Auth0-Client = %s", got)
+	}
+	if body["grant_type"] != "refresh_token" || body["refresh_token"] != "refresh-old" || body["client_id"] != AppClientId {
+		t.Errorf("unexpected request body %v", body)
+	}
+}
+
+func TestRefreshTokenExpiresInFallback(t *testing.T) {
+	server := newTokenServer(t, map[string]any{
+		"access_token": "opaque.access.token",
+		"expires_in":   3600,
+	})
+	auth := NewAuthentication("", "", expiredToken(), WithAuthBasePath(server.URL), WithAuthClock(testClock()))
+
+	if err := auth.RefreshToken(); err != nil {
+		t.Fatal(err)
+	}
+	token := auth.currentToken()
+	if token.AccessTokenIssuedAt != testNow.Unix() || token.AccessTokenExpiresAt != testNow.Add(time.Hour).Unix() {
+		t.Errorf("iat, exp = %d, %d, want %d, %d", token.AccessTokenIssuedAt, token.AccessTokenExpiresAt,
+			testNow.Unix(), testNow.Add(time.Hour).Unix())
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		refreshToken string
+		want         string
+	}{
+		{name: "rotated", refreshToken: "refresh-new", want: "refresh-new"},
+		{name: "not rotated", refreshToken: "", want: "refresh-old"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := map[string]any{"access_token": testJWT(testNow, testNow.Add(time.Hour))}
+			if test.refreshToken != "" {
+				response["refresh_token"] = test.refreshToken
+			}
+			server := newTokenServer(t, response)
+			var stored *Token
+			auth := NewAuthentication("", "", expiredToken(), WithAuthBasePath(server.URL),
+				WithAuthClock(testClock()))
+			auth.tokenListener = func(token *Token) { stored = token }
+
+			if err := auth.RefreshToken(); err != nil {
+				t.Fatal(err)
+			}
+			if got := auth.currentToken().RefreshToken; got != test.want {
+				t.Errorf("refresh token = %s, want %s", got, test.want)
+			}
+			if stored == nil || stored.RefreshToken != test.want {
+				t.Errorf("stored token %+v, want refresh token %s", stored, test.want)
+			}
+		})
+	}
+}
+
+func TestLoginRefreshesWithinLeeway(t *testing.T) {
+	tests := []struct {
+		name    string
+		leeway  time.Duration
+		refresh bool
+	}{
+		{name: "outside leeway", leeway: 10 * time.Second, refresh: false},
+		{name: "within leeway", leeway: 30 * time.Second, refresh: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTokenServer(t, map[string]any{"access_token": testJWT(testNow, testNow.Add(time.Hour))})
+			expiresSoon := testNow.Add(20 * time.Second)
+			token := expiredToken()
+			token.AccessToken = testJWT(testNow.Add(-time.Hour), expiresSoon)
+			token.AccessTokenIssuedAt, token.AccessTokenExpiresAt = testNow.Add(-time.Hour).Unix(), expiresSoon.Unix()
+			auth := NewAuthentication("", "", token, WithAuthBasePath(server.URL), WithAuthClock(testClock()),
+				WithTokenLeeway(test.leeway))
+
+			if err := auth.Login(); err != nil {
+				t.Fatal(err)
+			}
+			if refreshed := len(server.requests) > 0; refreshed != test.refresh {
+				t.Errorf("refreshed = %t, want %t", refreshed, test.refresh)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenConcurrentWithLogin(t *testing.T) {
+	server := newTokenServer(t, map[string]any{"access_token": testJWT(testNow, testNow.Add(time.Hour))})
+	auth := NewAuthentication("", "", expiredToken(), WithAuthBasePath(server.URL), WithAuthClock(testClock()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := auth.RefreshToken(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := auth.Login(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if !auth.hasValidToken() {
+		t.Error("no valid token after concurrent refreshes")
+	}
+}
@@ -0,0 +1,97 @@
+package comfortcloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrLoginRequired is returned when a new token is needed but no username and password are configured.
+// Use BeginBrowserLogin and CompleteBrowserLogin to log in.
+var ErrLoginRequired = errors.New("no stored credentials, a browser login is required")
+
+// BrowserLogin is a login started with BeginBrowserLogin. It can be stored until the user has completed
+// the login in the browser, e.g. when both steps run in separate processes.
+type BrowserLogin struct {
+	AuthorizeURL string `json:"authorize_url"`
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// BeginBrowserLogin starts a login that is completed in a browser. The user opens AuthorizeURL, logs in
+// and copies the panasonic-iot-cfc:// URL the browser is redirected to, which is passed to CompleteBrowserLogin.
+// The login uses the default Panasonic ID, see Authentication.BeginBrowserLogin for a login that uses the
+// base path set with WithAuthBasePath.
+func BeginBrowserLogin() *BrowserLogin {
+	return beginBrowserLogin(BasePathAuth)
+}
+
+// BeginBrowserLogin starts a login at the Panasonic ID of a, see the function BeginBrowserLogin.
+func (a *Authentication) BeginBrowserLogin() *BrowserLogin {
+	return beginBrowserLogin(a.authBasePath)
+}
+
+func beginBrowserLogin(authBasePath string) *BrowserLogin {
+	state, codeVerifier, codeChallenge := generateOAuthParameters()
+	return &BrowserLogin{
+		AuthorizeURL: authorizeURL(authBasePath, codeChallenge, state),
+		State:        state,
+		CodeVerifier: codeVerifier,
+	}
+}
+
+// CompleteBrowserLogin exchanges the authorization code in redirectURL for a token.
+func (a *Authentication) CompleteBrowserLogin(login *BrowserLogin, redirectURL string) error {
+	parsedURL, err := url.Parse(redirectURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse redirect URL: %w", err)
+	}
+	query := parsedURL.Query()
+	if errorCode := query.Get("error"); errorCode != "" {
+		return fmt.Errorf("browser login failed: %s: %s", errorCode, query.Get("error_description"))
+	}
+	if query.Get("state") != login.State {
+		return fmt.Errorf("browser login failed: redirect URL does not belong to this login")
+	}
+	if query.Get("code") == "" {
+		return fmt.Errorf("browser login failed: redirect URL contains no authorization code")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	client := &http.Client{}
+	tokenResponse, err := a.getToken(redirectURL, login.CodeVerifier, client)
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	return a.loginToAcc(tokenResponse, client)
+}
+
+// NewTokenClient creates a client that only uses the token stored in tokenFileName and never needs a password.
+// If there is no usable token, Login returns ErrLoginRequired and BeginBrowserLogin starts a new login.
+// The token file only keeps the refresh token, the ACC client ID and the app version; the access token
+// is obtained by refreshing when the file is loaded.
+func NewTokenClient(tokenFileName string, options ...ClientOption) *Client {
+	c := NewClient("", "", tokenFileName, options...)
+	c.refreshTokenOnly = true
+	return c
+}
+
+// refreshTokenFile is the content of the token file of a NewTokenClient.
+type refreshTokenFile struct {
+	RefreshToken string `json:"refresh_token"`
+	AccClientID  string `json:"acc_client_id"`
+	AppVersion   string `json:"app_version,omitempty"`
+}
+
+// BeginBrowserLogin starts a login that is completed in a browser with CompleteBrowserLogin.
+func (c *Client) BeginBrowserLogin() *BrowserLogin {
+	return c.auth.BeginBrowserLogin()
+}
+
+// CompleteBrowserLogin completes a login started with BeginBrowserLogin. The token is stored in the token file.
+func (c *Client) CompleteBrowserLogin(login *BrowserLogin, redirectURL string) error {
+	return c.auth.CompleteBrowserLogin(login, redirectURL)
+}
@@ -0,0 +1,53 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenClientStoresOnlyRefreshToken(t *testing.T) {
+	server := newTokenServer(t, map[string]any{
+		"access_token":  testJWT(testNow, testNow.Add(time.Hour)),
+		"refresh_token": "refresh-new",
+		"id_token":      "id",
+		"scope":         "openid offline_access",
+	})
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	stored := `{"refresh_token":"refresh-old","acc_client_id":"acc-client","app_version":"1.21.0"}`
+	if err := os.WriteFile(fileName, []byte(stored), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTokenClient(fileName, WithClock(testClock()), WithAuthOptions(WithAuthBasePath(server.URL)))
+	if err := client.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.bodies) != 1 || server.bodies[0]["refresh_token"] != "refresh-old" {
+		t.Fatalf("refresh requests %v, want one with the stored refresh token", server.bodies)
+	}
+	token := client.auth.currentToken()
+	if token.AccClientID != "acc-client" || token.AppVersion != "1.21.0" || token.AccessToken == "" {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file map[string]string
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"refresh_token": "refresh-new", "acc_client_id": "acc-client", "app_version": "1.21.0"}
+	if len(file) != len(want) {
+		t.Errorf("token file %v, want %v", file, want)
+	}
+	for key, value := range want {
+		if file[key] != value {
+			t.Errorf("token file %s = %q, want %q", key, file[key], value)
+		}
+	}
+}
@@ -1,60 +1,101 @@
 package comfortcloud
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"time"
 )
 
 type Client struct {
 	auth          *Authentication
-	groups        []Group
-	devices       []Device
+	registry      *deviceRegistry
+	status        *statusCache
+	aliases       map[string]string
 	tokenFileName string
+	clock         Clock
+	connectivity  *connectivityTracker
+	audit         *AuditLog
+	dryRun        bool
+	planListener  func(*Plan)
+	// refreshTokenOnly limits the token file to what is needed to refresh the token.
+	refreshTokenOnly bool
+
+	groupStatusMaxAge time.Duration
+	statusConcurrency int
 }
 
-func NewClient(username string, password string, tokenFileName string) *Client {
+func NewClient(username string, password string, tokenFileName string, options ...ClientOption) *Client {
 	auth := NewAuthentication(username, password, nil)
 
-	return &Client{
+	c := &Client{
 		auth:          auth,
+		registry:      newDeviceRegistry(),
 		tokenFileName: tokenFileName,
+		clock:         systemClock{},
+		connectivity:  newConnectivityTracker(),
+
+		groupStatusMaxAge: DefaultGroupStatusMaxAge,
+		statusConcurrency: DefaultStatusConcurrency,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.status != nil {
+		c.status.clock = c.clock
 	}
+	// Persist every new token, including refreshed tokens and app version updates
+	auth.tokenListener = func(token *Token) {
+		if err := c.saveToken(token); err != nil {
+			slog.Warn("Failed to persist token", "error", err)
+		}
+	}
+	return c
 }
 
+// Login loads the token from the token file and refreshes it if necessary. A new token is only requested
+// if the token cannot be refreshed.
 func (c *Client) Login() error {
-	if c.auth.token.isValid() {
+	if c.auth.hasValidToken() {
 		return nil
 	}
-	var token Token
-	tokenFile, err := os.ReadFile(c.tokenFileName)
-	if err == nil {
-		if err := json.Unmarshal(tokenFile, &token); err == nil {
-			if token.isValid() {
-				c.auth.token = &token
-				return nil
-			}
-		}
+	c.loadToken()
+	if err := c.ensureLoggedIn(); err != nil {
+		return fmt.Errorf("failed to login to Comfort Cloud: %w", err)
 	}
-	err2 := c.auth.Login()
-	if err2 != nil {
-		return fmt.Errorf("token file invalid: %w, failed to login to Comfort Cloud. %w", err, err2)
+	return nil
+}
+
+// loadToken loads the token from the token file if the client does not hold a token yet.
+func (c *Client) loadToken() {
+	if c.tokenFileName == "" {
+		return
 	}
-	updatedToken := c.auth.token
-	tokenJSON, err := json.MarshalIndent(updatedToken, "", "  ")
+	tokenFile, err := os.ReadFile(c.tokenFileName)
 	if err != nil {
-		return fmt.Errorf("failed to marshal token: %w", err)
+		return
 	}
-
-	if err := os.WriteFile(c.tokenFileName, tokenJSON, 0644); err != nil {
-		return fmt.Errorf("failed to write token file: %w", err)
+	var token Token
+	if err := json.Unmarshal(tokenFile, &token); err != nil {
+		return
+	}
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+	if c.auth.token != nil {
+		return
+	}
+	c.auth.token = &token
+	if token.AppVersion != "" {
+		c.auth.appVersion = token.AppVersion
 	}
-	return nil
 }
 
 func (c *Client) ensureLoggedIn() error {
@@ -65,86 +106,101 @@ func (c *Client) ensureLoggedIn() error {
 	return nil
 }
 
-func (c *Client) Logout() error {
-	return c.auth.Logout()
-}
-
-func (c *Client) FetchGroupsAndDevices() error {
-	// Ensure the client is logged in
-	if err := c.ensureLoggedIn(); err != nil {
-		return err
+func (c *Client) saveToken(token *Token) error {
+	if c.tokenFileName == "" || token == nil {
+		return nil
 	}
-
-	// Fetch and parse groups
-	groupURL := c.getGroupURL()
-	response, err := c.auth.ExecuteGet(groupURL, "get_groups", http.StatusOK)
+	var stored any = token
+	if c.refreshTokenOnly {
+		stored = refreshTokenFile{
+			RefreshToken: token.RefreshToken,
+			AccClientID:  token.AccClientID,
+			AppVersion:   token.AppVersion,
+		}
+	}
+	tokenJSON, err := json.MarshalIndent(stored, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to fetch groups: %w", err)
+		return fmt.Errorf("failed to marshal token: %w", err)
 	}
 
-	var result Response
-	if err := json.Unmarshal(response, &result); err != nil {
-		return fmt.Errorf("failed to parse groups response: %w", err)
+	if err := os.WriteFile(c.tokenFileName, tokenJSON, 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
 	}
+	return nil
+}
 
-	// Reset devices
-	c.groups = result.GroupList
-	c.devices = nil
-
-	// Populate devices
-	for _, group := range c.groups {
-		for _, device := range group.DeviceList {
-			// Append to devices slice
-			c.devices = append(c.devices, device)
+// Logout logs out of Comfort Cloud, revokes the refresh token and deletes the token file. If the client
+// has not logged in yet, the token is loaded from the token file first, so that it is revoked as well.
+// Logout also clears the username and password: afterwards Login returns ErrLoginRequired until a browser
+// login completes, and a new client has to be created to log in with a password again.
+// The returned error joins the failures of the individual steps.
+func (c *Client) Logout() error {
+	c.loadToken()
+	err := c.auth.Logout()
+	if c.tokenFileName != "" {
+		if removeErr := os.Remove(c.tokenFileName); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = errors.Join(err, fmt.Errorf("failed to remove token file: %w", removeErr))
 		}
-		fmt.Println(c.devices)
 	}
+	return err
+}
 
-	return nil
+// FetchGroupsAndDevices fetches the group and device list, bypassing the cache.
+func (c *Client) FetchGroupsAndDevices() error {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	return c.refreshDevices()
 }
 
+// GetDevices returns all devices, using the cache when it is fresh.
 func (c *Client) GetDevices() ([]Device, error) {
-	err := c.FetchGroupsAndDevices()
-	if err != nil {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	if err := c.ensureDevices(); err != nil {
 		return nil, err
 	}
-	return c.devices, nil
+	return append([]Device(nil), c.registry.devices...), nil
 }
 
+// GetDevice returns the device with its current status. With WithStatusCache, recent statuses are served from the cache.
 func (c *Client) GetDevice(deviceID string) (*Device, error) {
-	// Ensure the client is logged in
-	if err := c.ensureLoggedIn(); err != nil {
+	device, err := c.ResolveDevice(deviceID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Find the device by DeviceHashGuid or DeviceGuid
-	var device *Device
-	for _, d := range c.devices {
-		if d.DeviceHashGuid == deviceID || d.DeviceGuid == deviceID {
-			device = &d
-			break
-		}
+	if c.status == nil {
+		return c.fetchDeviceStatus(*device)
 	}
+	return c.status.get(*device, c.fetchDeviceStatus)
+}
 
-	if device == nil {
-		return nil, fmt.Errorf("device not found: %s", deviceID)
+func (c *Client) fetchDeviceStatus(device Device) (*Device, error) {
+	// Ensure the client is logged in
+	if err := c.ensureLoggedIn(); err != nil {
+		return nil, err
 	}
 
 	// Fetch device status using DeviceGuid
 	deviceURL := c.getDeviceStatusURL(device.DeviceGuid)
 	response, err := c.auth.ExecuteGet(deviceURL, "get_device", http.StatusOK)
 	if err != nil {
+		var apiError *APIError
+		if errors.As(err, &apiError) && apiError.DeviceOffline() {
+			c.markOffline(device)
+			return nil, fmt.Errorf("failed to fetch device status: %w: %w", ErrDeviceOffline, err)
+		}
 		return nil, fmt.Errorf("failed to fetch device status: %w", err)
 	}
-	fmt.Println("Device Status")
-	fmt.Println(string(response))
-	// Parse response
 
+	// Parse response
 	if err := json.Unmarshal(response, &device); err != nil {
 		return nil, fmt.Errorf("failed to parse device status: %w", err)
 	}
+	device.Raw = response
+	c.setConnectivity(&device)
 
-	return device, nil
+	return &device, nil
 }
 
 func hashMD5(s string) string {
@@ -153,51 +209,105 @@ func hashMD5(s string) string {
 }
 
 func (c *Client) SetDevice(deviceID string, options ...DeviceOption) error {
+	return c.SetDeviceContext(context.Background(), deviceID, options...)
+}
+
+// SetDeviceContext is SetDevice with a context. The caller set with WithCaller is recorded in the audit log.
+func (c *Client) SetDeviceContext(ctx context.Context, deviceID string, options ...DeviceOption) error {
 	parameter := &ParameterOptions{}
 	for _, option := range options {
 		option(parameter)
 	}
 
-	var device *Device
-	for _, d := range c.devices {
-		if d.DeviceHashGuid == deviceID || d.DeviceGuid == deviceID {
-			device = &d
-			break
+	device, err := c.ResolveDevice(deviceID)
+	if err != nil {
+		// The reference could not be resolved to a DeviceGuid, it is recorded as the name
+		c.auditSetDevice(ctx, Device{DeviceName: deviceID}, *parameter, nil, "", err)
+		return err
+	}
+
+	if c.dryRun {
+		return c.dryRunSetDevice(ctx, *device, parameter)
+	}
+
+	// The previous state is only needed for the audit log and is best effort. GetDevice serves it from the
+	// status cache if there is one.
+	var previous *Parameters
+	if c.audit != nil {
+		if current, err := c.GetDevice(device.DeviceGuid); err == nil {
+			previous = &current.Parameters
 		}
 	}
 
-	payload := map[string]interface{}{
-		"deviceGuid": device.DeviceGuid,
-		"parameters": parameter,
+	err = c.setDevice(ctx, *device, parameter)
+	c.auditSetDevice(ctx, *device, *parameter, previous, "", err)
+	return err
+}
+
+func (c *Client) setDevice(ctx context.Context, device Device, parameter *ParameterOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// A unit that was offline is checked again, commands to it would be accepted but never executed
+	if c.connectivity.isOffline(device.DeviceGuid) {
+		current, err := c.fetchDeviceStatus(device)
+		if err != nil {
+			return err
+		}
+		if current.offline() {
+			return fmt.Errorf("%w: %s was last seen %s", ErrDeviceOffline, device.DeviceName,
+				current.LastSeen.Format(time.RFC3339))
+		}
 	}
 
 	// Send the POST request to update the device
-	_, err := c.auth.ExecutePost(c.getDeviceStatusControlURL(), payload, "set_device", http.StatusOK)
+	_, err := c.auth.ExecutePost(c.getDeviceStatusControlURL(), controlPayload(device, parameter), "set_device",
+		http.StatusOK)
 	if err != nil {
+		var apiError *APIError
+		if errors.As(err, &apiError) && apiError.DeviceOffline() {
+			c.markOffline(device)
+			return fmt.Errorf("failed to set device parameters: %w: %w", ErrDeviceOffline, err)
+		}
 		return fmt.Errorf("failed to set device parameters: %w", err)
 	}
+	if c.status != nil {
+		c.status.invalidate(device.DeviceGuid)
+	}
+	c.registry.mu.Lock()
+	c.registry.markChanged(device.DeviceGuid)
+	c.registry.mu.Unlock()
 
 	return nil
 }
 
+// controlPayload returns the body of a /deviceStatus/control request.
+func controlPayload(device Device, parameter *ParameterOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"deviceGuid": device.DeviceGuid,
+		"parameters": parameter,
+	}
+}
+
 // getGroupURL returns the URL for retrieving groups.
 func (c *Client) getGroupURL() string {
 	//return "http://localhost:8080"
-	return fmt.Sprintf("%s/device/group", BasePathAcc)
+	return fmt.Sprintf("%s/device/group", c.auth.accBasePath)
 }
 
 // getDeviceStatusURL returns the URL for retrieving device status.
 func (c *Client) getDeviceStatusURL(guid string) string {
 	escapedGUID := regexp.MustCompile(`(?i)%2f`).ReplaceAllString(url.QueryEscape(guid), "f")
-	return fmt.Sprintf("%s/deviceStatus/%s", BasePathAcc, escapedGUID)
+	return fmt.Sprintf("%s/deviceStatus/%s", c.auth.accBasePath, escapedGUID)
 }
 
 // getDeviceStatusControlURL returns the URL for controlling device status.
 func (c *Client) getDeviceStatusControlURL() string {
-	return fmt.Sprintf("%s/deviceStatus/control", BasePathAcc)
+	return fmt.Sprintf("%s/deviceStatus/control", c.auth.accBasePath)
 }
 
 // getDeviceHistoryURL returns the URL for retrieving device history.
 func (c *Client) getDeviceHistoryURL() string {
-	return fmt.Sprintf("%s/deviceHistoryData", BasePathAcc)
+	return fmt.Sprintf("%s/deviceHistoryData", c.auth.accBasePath)
 }
@@ -0,0 +1,62 @@
+package comfortcloud
+
+import "time"
+
+type ClientOption func(*Client)
+
+// WithDeviceCacheTTL sets how long the group and device list is reused before it is fetched again.
+// A TTL of zero disables the cache.
+func WithDeviceCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *Client) {
+		c.registry.ttl = ttl
+	}
+}
+
+// WithDeviceCacheFile persists the group and device list to the given file, so that
+// subsequent processes can reuse it until the TTL expires.
+func WithDeviceCacheFile(fileName string) ClientOption {
+	return func(c *Client) {
+		c.registry.fileName = fileName
+	}
+}
+
+// WithStatusCache enables caching of device statuses returned by GetDevice. Statuses younger than maxAge
+// are served from the cache, statuses younger than maxAge+staleAge are served while being refreshed
+// in the background. Concurrent requests for the same device share one API call.
+func WithStatusCache(maxAge, staleAge time.Duration) ClientOption {
+	return func(c *Client) {
+		c.status = newStatusCache(maxAge, staleAge)
+	}
+}
+
+// WithGroupStatusMaxAge sets how old the group listing may be for GetAllStatuses to use the parameters
+// embedded in it. A max age of zero always fetches the devices individually.
+func WithGroupStatusMaxAge(maxAge time.Duration) ClientOption {
+	return func(c *Client) {
+		c.groupStatusMaxAge = maxAge
+	}
+}
+
+// WithStatusConcurrency limits the number of parallel status requests made by GetAllStatuses.
+func WithStatusConcurrency(concurrency int) ClientOption {
+	return func(c *Client) {
+		c.statusConcurrency = concurrency
+	}
+}
+
+// WithAuthOptions applies options to the Authentication used by the client.
+func WithAuthOptions(options ...AuthOption) ClientOption {
+	return func(c *Client) {
+		for _, option := range options {
+			option(c.auth)
+		}
+	}
+}
+
+// WithRateLimit spaces the API requests of the client at least interval apart, measured with the clock of
+// the Authentication.
+func WithRateLimit(interval time.Duration) ClientOption {
+	return func(c *Client) {
+		c.auth.limiter = newRateLimiter(interval)
+	}
+}
@@ -0,0 +1,37 @@
+package comfortcloud
+
+import "time"
+
+// Clock provides the current time for request signing, token expiry and cache ages.
+// Replacing it makes these deterministic, e.g. in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithAuthClock sets the clock used for request signing and token expiry.
+func WithAuthClock(clock Clock) AuthOption {
+	return func(a *Authentication) {
+		a.clock = clock
+	}
+}
+
+// WithClock sets the clock used by the client and its Authentication.
+func WithClock(clock Clock) ClientOption {
+	return func(c *Client) {
+		c.clock = clock
+		c.auth.clock = clock
+	}
+}
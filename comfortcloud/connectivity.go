@@ -0,0 +1,116 @@
+package comfortcloud
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultOfflineAfter is how old the status timestamp of a device may be before the device is considered offline.
+// The cloud refreshes the timestamp whenever it reaches the unit, so an old timestamp means the status is a
+// cached copy of a unit that lost its connection.
+const DefaultOfflineAfter = 15 * time.Minute
+
+// ErrDeviceOffline is returned when a device is not connected to the cloud.
+var ErrDeviceOffline = errors.New("device is offline")
+
+// accCodeDeviceOffline is the error code the Comfort Cloud API returns when it cannot reach a unit.
+const accCodeDeviceOffline = 5005
+
+// DeviceOffline reports whether the API rejected the request because the device is not connected.
+func (e *APIError) DeviceOffline() bool {
+	return e.Code == accCodeDeviceOffline
+}
+
+// ConnectivityEvent is emitted when a device goes offline or comes back online.
+type ConnectivityEvent struct {
+	DeviceGuid string
+	DeviceName string
+	Online     bool
+	LastSeen   time.Time
+	Time       time.Time
+}
+
+// connectivityTracker remembers the last known connectivity of each device and reports changes.
+type connectivityTracker struct {
+	mu           sync.Mutex
+	offlineAfter time.Duration
+	online       map[string]bool
+	listener     func(ConnectivityEvent)
+}
+
+func newConnectivityTracker() *connectivityTracker {
+	return &connectivityTracker{offlineAfter: DefaultOfflineAfter, online: make(map[string]bool)}
+}
+
+// update records the connectivity of a device and emits an event if it changed. The first observation
+// of a device only emits an event if it is offline.
+func (t *connectivityTracker) update(device *Device, now time.Time) {
+	t.mu.Lock()
+	previous, known := t.online[device.DeviceGuid]
+	t.online[device.DeviceGuid] = device.Online
+	listener := t.listener
+	t.mu.Unlock()
+
+	if known && previous == device.Online || !known && device.Online {
+		return
+	}
+	slog.Info("Device connectivity changed", "device", device.DeviceName, "online", device.Online,
+		"lastSeen", device.LastSeen)
+	if listener != nil {
+		listener(ConnectivityEvent{
+			DeviceGuid: device.DeviceGuid,
+			DeviceName: device.DeviceName,
+			Online:     device.Online,
+			LastSeen:   device.LastSeen,
+			Time:       now,
+		})
+	}
+}
+
+// isOffline reports whether the device was offline when it was last seen.
+func (t *connectivityTracker) isOffline(guid string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	online, known := t.online[guid]
+	return known && !online
+}
+
+// setConnectivity sets Online and LastSeen of a device from its status timestamp. Without a timestamp the
+// connectivity is unknown: LastSeen stays zero, Online false, and the last known connectivity is kept.
+func (c *Client) setConnectivity(device *Device) {
+	if device.Timestamp <= 0 {
+		device.Online, device.LastSeen = false, time.Time{}
+		return
+	}
+	now := c.clock.Now()
+	device.LastSeen = time.UnixMilli(device.Timestamp)
+	device.Online = now.Sub(device.LastSeen) <= c.connectivity.offlineAfter
+	c.connectivity.update(device, now)
+}
+
+// offline reports whether the device is known to be offline, as opposed to a status without timestamp.
+func (d *Device) offline() bool {
+	return !d.Online && !d.LastSeen.IsZero()
+}
+
+// markOffline records that the API reported the device as not connected.
+func (c *Client) markOffline(device Device) {
+	device.Online = false
+	c.connectivity.update(&device, c.clock.Now())
+}
+
+// WithOfflineAfter sets how old the status timestamp of a device may be before it is considered offline.
+func WithOfflineAfter(offlineAfter time.Duration) ClientOption {
+	return func(c *Client) {
+		c.connectivity.offlineAfter = offlineAfter
+	}
+}
+
+// WithConnectivityListener registers a function that is called when a device goes offline or comes back online.
+func WithConnectivityListener(listener func(ConnectivityEvent)) ClientOption {
+	return func(c *Client) {
+		c.connectivity.listener = listener
+	}
+}
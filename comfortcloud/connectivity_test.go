@@ -0,0 +1,95 @@
+package comfortcloud
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPIErrorDeviceOffline(t *testing.T) {
+	tests := []struct {
+		name string
+		err  APIError
+		want bool
+	}{
+		{name: "offline code", err: APIError{StatusCode: http.StatusForbidden, Code: accCodeDeviceOffline}, want: true},
+		{name: "other code", err: APIError{StatusCode: http.StatusForbidden, Code: 4100, Message: "communication token invalid"}},
+		{name: "no code", err: APIError{StatusCode: http.StatusInternalServerError, Message: "device offline"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.err.DeviceOffline(); got != test.want {
+				t.Errorf("DeviceOffline() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetAllStatusesFromListingSetsConnectivity(t *testing.T) {
+	lastSeen := testNow.Add(-time.Hour)
+	client := newListedClient(
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room", Timestamp: testNow.Add(-time.Minute).UnixMilli()},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom", Timestamp: lastSeen.UnixMilli()},
+	)
+	var events []ConnectivityEvent
+	WithConnectivityListener(func(event ConnectivityEvent) { events = append(events, event) })(client)
+
+	statuses, err := client.GetAllStatuses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device := statuses["guid-1"].Device; device == nil || !device.Online {
+		t.Errorf("guid-1: got %+v, want online", statuses["guid-1"])
+	}
+	if device := statuses["guid-2"].Device; device == nil || device.Online || !device.LastSeen.Equal(lastSeen) {
+		t.Errorf("guid-2: got %+v, want offline since %s", statuses["guid-2"], lastSeen)
+	}
+	if len(events) != 1 || events[0].DeviceGuid != "guid-2" || events[0].Online {
+		t.Errorf("connectivity events %+v, want guid-2 offline", events)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetAllStatuses(ctx); err == nil {
+		t.Error("GetAllStatuses with a cancelled context succeeded")
+	}
+}
+
+func TestConnectivityWithoutTimestamp(t *testing.T) {
+	lastSeen := testNow.Add(-time.Hour)
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	server.statuses["guid-1"].Timestamp = lastSeen.UnixMilli()
+	var events []ConnectivityEvent
+	client := newFakeAccClient(t, server,
+		WithConnectivityListener(func(event ConnectivityEvent) { events = append(events, event) }))
+
+	device, err := client.GetDevice("guid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Online || !device.LastSeen.Equal(lastSeen) || len(events) != 1 {
+		t.Fatalf("got online %t, last seen %s and events %+v, want offline since %s", device.Online,
+			device.LastSeen, events, lastSeen)
+	}
+
+	server.mu.Lock()
+	server.statuses["guid-1"].Timestamp = 0
+	server.mu.Unlock()
+	device, err = client.GetDevice("guid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Online || !device.LastSeen.IsZero() || len(events) != 1 {
+		t.Errorf("got online %t, last seen %s and events %+v, want unknown connectivity without events",
+			device.Online, device.LastSeen, events)
+	}
+
+	// A device of unknown connectivity is not refused as offline.
+	if err := client.SetDevice("guid-1", WithPower(PowerOn)); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.controls) != 1 {
+		t.Errorf("got %d control requests, want 1", len(server.controls))
+	}
+}
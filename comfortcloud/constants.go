@@ -22,17 +22,6 @@ const (
 	PowerOn
 )
 
-func (p Power) String() string {
-	switch p {
-	case PowerOff:
-		return "Off"
-	case PowerOn:
-		return "On"
-	default:
-		return "Unknown"
-	}
-}
-
 type OperationMode int
 
 const (
@@ -105,6 +94,7 @@ const (
 	DataModeYear
 )
 
+// Deprecated: Use ParseDataMode, which also accepts lowercase names.
 var DataModeMap = map[string]DataMode{
 	"Day":   DataModeDay,
 	"Week":  DataModeWeek,
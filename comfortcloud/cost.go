@@ -0,0 +1,412 @@
+package comfortcloud
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// costSampleInterval is the resolution with which the rate of buckets longer than an hour is averaged.
+// Consumption is assumed to be spread evenly over such buckets.
+const costSampleInterval = 15 * time.Minute
+
+// TariffConfig is the tariff configuration loaded by LoadTariffConfig, e.g.
+//
+//	{
+//	  "currency": "EUR",
+//	  "default": "home",
+//	  "tariffs": {
+//	    "home": {
+//	      "rate": 0.32, "weekendRate": 0.28, "standingCharge": 0.45,
+//	      "windows": [{"start": "22:00", "end": "06:00", "rate": 0.21}]
+//	    }
+//	  },
+//	  "groups": {"Office": "business"},
+//	  "devices": {"Bedroom": "home"}
+//	}
+//
+// Devices and groups map device GUIDs, hash GUIDs or names and group names to tariff names. Devices
+// without an entry use the tariff of their group, then the default tariff.
+type TariffConfig struct {
+	Currency string            `json:"currency"`
+	Default  string            `json:"default"`
+	Tariffs  map[string]Tariff `json:"tariffs"`
+	Groups   map[string]string `json:"groups,omitempty"`
+	Devices  map[string]string `json:"devices,omitempty"`
+}
+
+// Tariff is an electricity tariff. The rate of the first matching window applies, otherwise WeekendRate
+// on Saturdays and Sundays if set, otherwise Rate. Rates are per kWh, StandingCharge is per day.
+type Tariff struct {
+	Rate           float64        `json:"rate"`
+	WeekendRate    *float64       `json:"weekendRate,omitempty"`
+	StandingCharge float64        `json:"standingCharge,omitempty"`
+	Windows        []TariffWindow `json:"windows,omitempty"`
+}
+
+// TariffWindow is a time-of-use window from Start to End local time. A window ending before it starts
+// spans midnight. Without Days the window applies every day.
+type TariffWindow struct {
+	Start ClockTime `json:"start"`
+	End   ClockTime `json:"end"`
+	Rate  float64   `json:"rate"`
+	Days  Weekdays  `json:"days,omitempty"`
+}
+
+// ClockTime is a time of day in minutes since midnight, written as "HH:MM". "24:00" is allowed as end of day.
+type ClockTime int
+
+func (t ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+func (t ClockTime) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *ClockTime) UnmarshalText(text []byte) error {
+	hours, minutes, ok := strings.Cut(string(text), ":")
+	h, err1 := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if !ok || err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return fmt.Errorf("invalid time of day %q", text)
+	}
+	*t = ClockTime(h*60 + m)
+	return nil
+}
+
+// Weekdays is a set of days, written as a list of English day names or their first three letters.
+type Weekdays []time.Weekday
+
+func (d Weekdays) MarshalJSON() ([]byte, error) {
+	names := make([]string, len(d))
+	for i, day := range d {
+		names[i] = strings.ToLower(day.String()[:3])
+	}
+	return json.Marshal(names)
+}
+
+func (d *Weekdays) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	days := make(Weekdays, 0, len(names))
+	for _, name := range names {
+		day, err := parseWeekday(name)
+		if err != nil {
+			return err
+		}
+		days = append(days, day)
+	}
+	*d = days
+	return nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if lower := strings.ToLower(strings.TrimSpace(name)); lower == full || lower == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+func (d Weekdays) contains(day time.Weekday) bool {
+	if len(d) == 0 {
+		return true
+	}
+	for _, candidate := range d {
+		if candidate == day {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTariffConfig reads a TariffConfig from a JSON file and checks that all referenced tariffs exist.
+func LoadTariffConfig(fileName string) (*TariffConfig, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tariff file: %w", err)
+	}
+	var config TariffConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse tariff file: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that the default tariff and all tariffs referenced by groups and devices exist.
+func (c *TariffConfig) Validate() error {
+	var errs []error
+	check := func(kind, key, name string) {
+		if _, ok := c.Tariffs[name]; !ok {
+			errs = append(errs, fmt.Errorf("%s %s: unknown tariff %q", kind, key, name))
+		}
+	}
+	if c.Default != "" {
+		check("default", "tariff", c.Default)
+	}
+	for group, name := range c.Groups {
+		check("group", group, name)
+	}
+	for device, name := range c.Devices {
+		check("device", device, name)
+	}
+	return errors.Join(errs...)
+}
+
+// TariffFor returns the name and tariff that apply to a device in a group.
+func (c *TariffConfig) TariffFor(device Device, group string) (string, Tariff, error) {
+	for _, key := range []string{device.DeviceGuid, device.DeviceHashGuid, device.DeviceName} {
+		if name, ok := c.Devices[key]; ok && key != "" {
+			return name, c.Tariffs[name], nil
+		}
+	}
+	if name, ok := c.Groups[group]; ok {
+		return name, c.Tariffs[name], nil
+	}
+	if tariff, ok := c.Tariffs[c.Default]; ok {
+		return c.Default, tariff, nil
+	}
+	return "", Tariff{}, fmt.Errorf("no tariff configured for device %s", device.DeviceName)
+}
+
+// RateAt returns the rate per kWh at the given time, which is evaluated in its own location.
+func (t Tariff) RateAt(at time.Time) float64 {
+	minute := ClockTime(at.Hour()*60 + at.Minute())
+	for _, window := range t.Windows {
+		day := at.Weekday()
+		var inWindow bool
+		if window.Start <= window.End {
+			inWindow = minute >= window.Start && minute < window.End
+		} else if minute >= window.Start {
+			inWindow = true
+		} else if minute < window.End {
+			// The part after midnight belongs to the window that started the day before.
+			inWindow = true
+			day = (day + 6) % 7
+		}
+		if inWindow && window.Days.contains(day) {
+			return window.Rate
+		}
+	}
+	if t.WeekendRate != nil && (at.Weekday() == time.Saturday || at.Weekday() == time.Sunday) {
+		return *t.WeekendRate
+	}
+	return t.Rate
+}
+
+// RecordCost returns the energy cost of a history record. Records without data cost nothing.
+func (t Tariff) RecordCost(record HistoryRecord) float64 {
+	if !record.HasData() {
+		return 0
+	}
+	if record.End.Sub(record.Start) <= time.Hour {
+		return record.Consumption * t.RateAt(record.Start)
+	}
+	var sum float64
+	var samples int
+	for at := record.Start; at.Before(record.End); at = at.Add(costSampleInterval) {
+		sum += t.RateAt(at)
+		samples++
+	}
+	return record.Consumption * sum / float64(samples)
+}
+
+// StandingCharges returns the standing charge for the calendar days from start up to end.
+func (t Tariff) StandingCharges(start, end time.Time) float64 {
+	days := 0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days++
+	}
+	return float64(days) * t.StandingCharge
+}
+
+// CostReport is the energy cost of a set of devices over a period. Standing charges are counted once per
+// tariff and are included in Total but not in the device, group and period costs.
+type CostReport struct {
+	Start           time.Time    `json:"start"`
+	End             time.Time    `json:"end"`
+	Currency        string       `json:"currency"`
+	Devices         []DeviceCost `json:"devices"`
+	Groups          []GroupCost  `json:"groups"`
+	Periods         []PeriodCost `json:"periods"`
+	StandingCharges float64      `json:"standingCharges"`
+	Energy          float64      `json:"energy"`
+	Total           float64      `json:"total"`
+}
+
+type DeviceCost struct {
+	DeviceGuid string  `json:"deviceGuid"`
+	DeviceName string  `json:"deviceName"`
+	Group      string  `json:"group"`
+	Tariff     string  `json:"tariff"`
+	Energy     float64 `json:"energy"`
+	Cost       float64 `json:"cost"`
+}
+
+type GroupCost struct {
+	Group  string  `json:"group"`
+	Energy float64 `json:"energy"`
+	Cost   float64 `json:"cost"`
+}
+
+// PeriodCost is the cost of all devices in one history bucket.
+type PeriodCost struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Energy float64   `json:"energy"`
+	Cost   float64   `json:"cost"`
+}
+
+// NewCostReport computes the costs of the given histories. The groups are used to find the group and name of
+// each device. All histories must cover the same period.
+func NewCostReport(config *TariffConfig, groups []Group, histories []*History) (*CostReport, error) {
+	report := &CostReport{Currency: config.Currency}
+	tariffs := make(map[string]bool)
+	groupCosts := make(map[string]*GroupCost)
+	periodCosts := make(map[time.Time]*PeriodCost)
+	var groupOrder []string
+
+	for _, history := range histories {
+		device, group := findDeviceInGroups(groups, history.DeviceGuid)
+		tariffName, tariff, err := config.TariffFor(device, group)
+		if err != nil {
+			return nil, err
+		}
+		tariffs[tariffName] = true
+
+		deviceCost := DeviceCost{DeviceGuid: history.DeviceGuid, DeviceName: device.DeviceName, Group: group, Tariff: tariffName}
+		for _, record := range history.HistoryDataList {
+			if report.Start.IsZero() || record.Start.Before(report.Start) {
+				report.Start = record.Start
+			}
+			if record.End.After(report.End) {
+				report.End = record.End
+			}
+			if !record.HasData() {
+				continue
+			}
+			cost := tariff.RecordCost(record)
+			deviceCost.Energy += record.Consumption
+			deviceCost.Cost += cost
+
+			period, ok := periodCosts[record.Start]
+			if !ok {
+				period = &PeriodCost{Start: record.Start, End: record.End}
+				periodCosts[record.Start] = period
+			}
+			period.Energy += record.Consumption
+			period.Cost += cost
+		}
+		report.Devices = append(report.Devices, deviceCost)
+
+		groupCost, ok := groupCosts[group]
+		if !ok {
+			groupCost = &GroupCost{Group: group}
+			groupCosts[group] = groupCost
+			groupOrder = append(groupOrder, group)
+		}
+		groupCost.Energy += deviceCost.Energy
+		groupCost.Cost += deviceCost.Cost
+		report.Energy += deviceCost.Energy
+		report.Total += deviceCost.Cost
+	}
+
+	for _, group := range groupOrder {
+		report.Groups = append(report.Groups, *groupCosts[group])
+	}
+	for _, period := range periodCosts {
+		report.Periods = append(report.Periods, *period)
+	}
+	sort.Slice(report.Periods, func(i, j int) bool { return report.Periods[i].Start.Before(report.Periods[j].Start) })
+
+	for name := range tariffs {
+		report.StandingCharges += config.Tariffs[name].StandingCharges(report.Start, report.End)
+	}
+	report.Total += report.StandingCharges
+	return report, nil
+}
+
+func findDeviceInGroups(groups []Group, guid string) (Device, string) {
+	for _, group := range groups {
+		for _, device := range group.DeviceList {
+			if device.DeviceGuid == guid {
+				return device, group.GroupName
+			}
+		}
+	}
+	return Device{DeviceGuid: guid, DeviceName: guid}, ""
+}
+
+// GetCostReport fetches the history of all devices for the period of mode containing date and computes its cost.
+func (c *Client) GetCostReport(config *TariffConfig, mode DataMode, date time.Time) (*CostReport, error) {
+	groups, err := c.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	var histories []*History
+	for _, group := range groups {
+		for _, device := range group.DeviceList {
+			history, err := c.GetDeviceHistory(device.DeviceGuid, mode, date)
+			if err != nil {
+				return nil, fmt.Errorf("device %s: %w", device.DeviceName, err)
+			}
+			histories = append(histories, history)
+		}
+	}
+	return NewCostReport(config, groups, histories)
+}
+
+// GetMonthlyCostReport returns the cost report for the month containing month.
+func (c *Client) GetMonthlyCostReport(config *TariffConfig, month time.Time) (*CostReport, error) {
+	return c.GetCostReport(config, DataModeMonth, month)
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *CostReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per device, group and period and a total row.
+func (r *CostReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	formatFloat := func(value float64) string { return strconv.FormatFloat(value, 'f', 3, 64) }
+	formatTime := func(t time.Time) string { return t.Format(time.RFC3339) }
+	rows := [][]string{{"type", "name", "group", "tariff", "start", "end", "energy_kwh", "cost", "currency"}}
+	start, end := formatTime(r.Start), formatTime(r.End)
+	for _, device := range r.Devices {
+		rows = append(rows, []string{"device", device.DeviceName, device.Group, device.Tariff, start, end,
+			formatFloat(device.Energy), formatFloat(device.Cost), r.Currency})
+	}
+	for _, group := range r.Groups {
+		rows = append(rows, []string{"group", group.Group, group.Group, "", start, end,
+			formatFloat(group.Energy), formatFloat(group.Cost), r.Currency})
+	}
+	for _, period := range r.Periods {
+		rows = append(rows, []string{"period", "", "", "", formatTime(period.Start), formatTime(period.End),
+			formatFloat(period.Energy), formatFloat(period.Cost), r.Currency})
+	}
+	rows = append(rows,
+		[]string{"standing-charge", "", "", "", start, end, "", formatFloat(r.StandingCharges), r.Currency},
+		[]string{"total", "", "", "", start, end, formatFloat(r.Energy), formatFloat(r.Total), r.Currency})
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write cost report: %w", err)
+	}
+	return nil
+}
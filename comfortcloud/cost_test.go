@@ -0,0 +1,179 @@
+package comfortcloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTariff has a Friday late-night window crossing midnight, a night window and a weekday peak window.
+func testTariff(t *testing.T) Tariff {
+	var tariff Tariff
+	err := json.Unmarshal([]byte(`{
+		"rate": 0.30, "weekendRate": 0.25, "standingCharge": 0.45,
+		"windows": [
+			{"start": "23:00", "end": "01:00", "rate": 0.10, "days": ["fri"]},
+			{"start": "22:00", "end": "06:00", "rate": 0.20},
+			{"start": "17:00", "end": "19:00", "rate": 0.40, "days": ["Monday", "tue", "wed", "thu", "fri"]}
+		]
+	}`), &tariff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tariff
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTariffRateAt(t *testing.T) {
+	tariff := testTariff(t)
+	// 2024-03-15 is a Friday.
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{name: "weekday", at: at(15, 12, 0), want: 0.30},
+		{name: "weekday peak", at: at(15, 17, 30), want: 0.40},
+		{name: "peak end is exclusive", at: at(15, 19, 0), want: 0.30},
+		{name: "weekend outside peak days", at: at(16, 17, 30), want: 0.25},
+		{name: "night start is inclusive", at: at(15, 22, 0), want: 0.20},
+		{name: "friday late night", at: at(15, 23, 30), want: 0.10},
+		{name: "friday late night after midnight", at: at(16, 0, 30), want: 0.10},
+		{name: "saturday night after midnight", at: at(17, 0, 30), want: 0.20},
+		{name: "thursday night after midnight", at: at(15, 0, 30), want: 0.20},
+		{name: "night end is exclusive", at: at(16, 6, 0), want: 0.25},
+		{name: "end of night", at: at(16, 5, 59), want: 0.20},
+		{name: "local time", at: time.Date(2024, 3, 15, 23, 30, 0, 0, time.FixedZone("CET", 3600)), want: 0.10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tariff.RateAt(test.at); !almostEqual(got, test.want) {
+				t.Errorf("RateAt(%s) = %g, want %g", test.at, got, test.want)
+			}
+		})
+	}
+
+	flat := Tariff{Rate: 0.30}
+	if got := flat.RateAt(at(16, 12, 0)); got != 0.30 {
+		t.Errorf("RateAt without weekend rate = %g, want 0.30", got)
+	}
+}
+
+func TestTariffRecordCost(t *testing.T) {
+	tariff := testTariff(t)
+	friday := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record HistoryRecord
+		want   float64
+	}{
+		{name: "hour", record: HistoryRecord{Consumption: 2, Start: friday.Add(17 * time.Hour),
+			End: friday.Add(18 * time.Hour)}, want: 0.80},
+		// 24 quarter hours at 0.20, 44 at 0.30, 8 at 0.40, 12 at 0.30, 4 at 0.20 and 4 at 0.10.
+		{name: "day", record: HistoryRecord{Consumption: 2.4, Start: friday, End: friday.AddDate(0, 0, 1)},
+			want: 2.4 * 26 / 96},
+		{name: "no data", record: HistoryRecord{Consumption: historyValueUnavailable, Start: friday,
+			End: friday.AddDate(0, 0, 1)}, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tariff.RecordCost(test.record); !almostEqual(got, test.want) {
+				t.Errorf("RecordCost = %g, want %g", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTariffStandingCharges(t *testing.T) {
+	tariff := Tariff{StandingCharge: 0.45}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available:", err)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		days       int
+	}{
+		{name: "month across DST", start: time.Date(2024, 3, 1, 0, 0, 0, 0, berlin),
+			end: time.Date(2024, 4, 1, 0, 0, 0, 0, berlin), days: 31},
+		{name: "part of a day", start: testNow, end: testNow.Add(2 * time.Hour), days: 1},
+		{name: "empty", start: testNow, end: testNow, days: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := tariff.StandingCharges(test.start, test.end); !almostEqual(got, float64(test.days)*0.45) {
+				t.Errorf("StandingCharges = %g, want %d days", got, test.days)
+			}
+		})
+	}
+}
+
+func testCostReport(t *testing.T) *CostReport {
+	config := &TariffConfig{Currency: "EUR", Default: "home",
+		Tariffs: map[string]Tariff{"home": {Rate: 0.30, StandingCharge: 0.5}}}
+	groups := []Group{{GroupName: "Home", DeviceList: []Device{
+		{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		{DeviceGuid: "guid-2", DeviceName: "Bedroom"},
+	}}}
+	hour := func(h int, consumption float64) HistoryRecord {
+		start := time.Date(2024, 3, 15, h, 0, 0, 0, time.UTC)
+		return HistoryRecord{Consumption: consumption, Start: start, End: start.Add(time.Hour)}
+	}
+	histories := []*History{
+		{DeviceGuid: "guid-1", HistoryDataList: []HistoryRecord{hour(10, 1), hour(11, 0.5)}},
+		{DeviceGuid: "guid-2", HistoryDataList: []HistoryRecord{hour(10, 2), hour(11, historyValueUnavailable)}},
+	}
+	report, err := NewCostReport(config, groups, histories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestCostReportWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testCostReport(t).WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"type,name,group,tariff,start,end,energy_kwh,cost,currency",
+		"device,Living room,Home,home,2024-03-15T10:00:00Z,2024-03-15T12:00:00Z,1.500,0.450,EUR",
+		"device,Bedroom,Home,home,2024-03-15T10:00:00Z,2024-03-15T12:00:00Z,2.000,0.600,EUR",
+		"group,Home,Home,,2024-03-15T10:00:00Z,2024-03-15T12:00:00Z,3.500,1.050,EUR",
+		"period,,,,2024-03-15T10:00:00Z,2024-03-15T11:00:00Z,3.000,0.900,EUR",
+		"period,,,,2024-03-15T11:00:00Z,2024-03-15T12:00:00Z,0.500,0.150,EUR",
+		"standing-charge,,,,2024-03-15T10:00:00Z,2024-03-15T12:00:00Z,,0.500,EUR",
+		"total,,,,2024-03-15T10:00:00Z,2024-03-15T12:00:00Z,3.500,1.550,EUR",
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestCostReportWriteJSON(t *testing.T) {
+	report := testCostReport(t)
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded CostReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Start.Equal(report.Start) || !decoded.End.Equal(report.End) || decoded.Currency != "EUR" ||
+		len(decoded.Devices) != 2 || len(decoded.Groups) != 1 || len(decoded.Periods) != 2 ||
+		!almostEqual(decoded.StandingCharges, 0.5) || !almostEqual(decoded.Energy, 3.5) || !almostEqual(decoded.Total, 1.55) {
+		t.Errorf("unexpected report %+v", decoded)
+	}
+	if !strings.Contains(buf.String(), "\n  \"currency\": \"EUR\",\n") {
+		t.Errorf("report is not indented:\n%s", buf.String())
+	}
+}
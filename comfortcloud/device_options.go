@@ -2,9 +2,12 @@ package comfortcloud
 
 type DeviceOption func(*ParameterOptions)
 
-func WithTemperature(temperature float64) DeviceOption {
+// WithTemperature sets the target temperature, rounded to the 0.5°C steps accepted by the units. The API only
+// accepts Celsius, so a Fahrenheit temperature is converted first: 70°F is sent as 21°C, which is 69.8°F.
+func WithTemperature(temperature Temperature) DeviceOption {
 	return func(o *ParameterOptions) {
-		o.TemperatureSet = &temperature
+		rounded := Celsius(roundToHalf(temperature.Celsius()))
+		o.TemperatureSet = &rounded
 	}
 }
 
@@ -0,0 +1,224 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// enumType maps the values of an integer enum to human-readable names. The API encodes enums as
+// integers, so JSON keeps using numbers while text encoding uses the names. String capitalizes the
+// name and returns "Unknown" for values unknown to the library, as Power.String always did.
+type enumType[T ~int] struct {
+	typeName string
+	values   []T
+	names    []string
+}
+
+func newEnumType[T ~int](typeName string, values []T, names []string) enumType[T] {
+	if len(values) != len(names) {
+		panic("comfortcloud: enum " + typeName + " has mismatched names")
+	}
+	return enumType[T]{typeName: typeName, values: values, names: names}
+}
+
+func (e enumType[T]) name(value T) (string, bool) {
+	for i, v := range e.values {
+		if v == value {
+			return e.names[i], true
+		}
+	}
+	return "", false
+}
+
+func (e enumType[T]) format(value T) string {
+	if name, ok := e.name(value); ok {
+		return strings.ToUpper(name[:1]) + name[1:]
+	}
+	return "Unknown"
+}
+
+// parse accepts a name case-insensitively, with "_" or " " in place of "-", or the integer value.
+func (e enumType[T]) parse(s string) (T, error) {
+	normalized := strings.NewReplacer("_", "-", " ", "-").Replace(strings.ToLower(strings.TrimSpace(s)))
+	for i, name := range e.names {
+		if name == normalized {
+			return e.values[i], nil
+		}
+	}
+	if number, err := strconv.Atoi(normalized); err == nil {
+		for _, v := range e.values {
+			if int(v) == number {
+				return v, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid %s %q, valid values are: %s", e.typeName, s, strings.Join(e.names, ", "))
+}
+
+func (e enumType[T]) all() []T {
+	return append([]T(nil), e.values...)
+}
+
+// marshalText uses the name of the value, or the integer for values unknown to the library.
+func (e enumType[T]) marshalText(value T) ([]byte, error) {
+	if name, ok := e.name(value); ok {
+		return []byte(name), nil
+	}
+	return []byte(strconv.Itoa(int(value))), nil
+}
+
+// unmarshalText accepts what parse accepts and, like unmarshalJSON, integers unknown to the library, so that
+// the output of marshalText always decodes.
+func (e enumType[T]) unmarshalText(value *T, text []byte) error {
+	if number, err := strconv.Atoi(strings.TrimSpace(string(text))); err == nil {
+		*value = T(number)
+		return nil
+	}
+	parsed, err := e.parse(string(text))
+	if err != nil {
+		return err
+	}
+	*value = parsed
+	return nil
+}
+
+// unmarshalJSON accepts the integer used by the API, including values unknown to the library, or a name.
+func (e enumType[T]) unmarshalJSON(value *T, data []byte) error {
+	var number int
+	if err := json.Unmarshal(data, &number); err == nil {
+		*value = T(number)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid %s %s", e.typeName, data)
+	}
+	return e.unmarshalText(value, []byte(text))
+}
+
+var (
+	powerType = newEnumType("power",
+		[]Power{PowerOff, PowerOn},
+		[]string{"off", "on"})
+	operationModeType = newEnumType("operation mode",
+		[]OperationMode{OperationModeAuto, OperationModeDry, OperationModeCool, OperationModeHeat, OperationModeFan},
+		[]string{"auto", "dry", "cool", "heat", "fan"})
+	airSwingUDType = newEnumType("vertical air swing",
+		[]AirSwingUD{AirSwingUDAuto, AirSwingUDUp, AirSwingUDUpMid, AirSwingUDMid, AirSwingUDDownMid, AirSwingUDDown, AirSwingUDSwing},
+		[]string{"auto", "up", "up-mid", "mid", "down-mid", "down", "swing"})
+	airSwingLRType = newEnumType("horizontal air swing",
+		[]AirSwingLR{AirSwingLRAuto, AirSwingLRLeft, AirSwingLRMid, AirSwingLRRightMid, AirSwingLRRight},
+		[]string{"auto", "left", "mid", "right-mid", "right"})
+	ecoModeType = newEnumType("eco mode",
+		[]EcoMode{EcoModeAuto, EcoModePowerful, EcoModeQuiet},
+		[]string{"auto", "powerful", "quiet"})
+	airSwingAutoModeType = newEnumType("air swing auto mode",
+		[]AirSwingAutoMode{AirSwingAutoModeDisabled, AirSwingAutoModeBoth, AirSwingAutoModeAirSwingUD, AirSwingAutoModeAirSwingLR},
+		[]string{"disabled", "both", "up-down", "left-right"})
+	fanSpeedType = newEnumType("fan speed",
+		[]FanSpeed{FanSpeedAuto, FanSpeedLow, FanSpeedLowMid, FanSpeedMid, FanSpeedHighMid, FanSpeedHigh},
+		[]string{"auto", "low", "low-mid", "mid", "high-mid", "high"})
+	dataModeType = newEnumType("data mode",
+		[]DataMode{DataModeDay, DataModeWeek, DataModeMonth, DataModeYear},
+		[]string{"day", "week", "month", "year"})
+	nanoeModeType = newEnumType("nanoe mode",
+		[]NanoeMode{NanoeModeUnavailable, NanoeModeOff, NanoeModeOn, NanoeModeModeG, NanoeModeAll},
+		[]string{"unavailable", "off", "on", "mode-g", "all"})
+	temperatureUnitType = newEnumType("temperature unit",
+		[]TemperatureUnit{TemperatureUnitCelsius, TemperatureUnitFahrenheit},
+		[]string{"celsius", "fahrenheit"})
+)
+
+func (p Power) String() string                   { return powerType.format(p) }
+func (p Power) MarshalText() ([]byte, error)     { return powerType.marshalText(p) }
+func (p *Power) UnmarshalText(text []byte) error { return powerType.unmarshalText(p, text) }
+func (p Power) MarshalJSON() ([]byte, error)     { return json.Marshal(int(p)) }
+func (p *Power) UnmarshalJSON(data []byte) error { return powerType.unmarshalJSON(p, data) }
+func ParsePower(s string) (Power, error)         { return powerType.parse(s) }
+func PowerValues() []Power                       { return powerType.all() }
+
+func (m OperationMode) String() string               { return operationModeType.format(m) }
+func (m OperationMode) MarshalText() ([]byte, error) { return operationModeType.marshalText(m) }
+func (m *OperationMode) UnmarshalText(text []byte) error {
+	return operationModeType.unmarshalText(m, text)
+}
+func (m OperationMode) MarshalJSON() ([]byte, error) { return json.Marshal(int(m)) }
+func (m *OperationMode) UnmarshalJSON(data []byte) error {
+	return operationModeType.unmarshalJSON(m, data)
+}
+func ParseOperationMode(s string) (OperationMode, error) { return operationModeType.parse(s) }
+func OperationModeValues() []OperationMode               { return operationModeType.all() }
+
+func (a AirSwingUD) String() string                   { return airSwingUDType.format(a) }
+func (a AirSwingUD) MarshalText() ([]byte, error)     { return airSwingUDType.marshalText(a) }
+func (a *AirSwingUD) UnmarshalText(text []byte) error { return airSwingUDType.unmarshalText(a, text) }
+func (a AirSwingUD) MarshalJSON() ([]byte, error)     { return json.Marshal(int(a)) }
+func (a *AirSwingUD) UnmarshalJSON(data []byte) error { return airSwingUDType.unmarshalJSON(a, data) }
+func ParseAirSwingUD(s string) (AirSwingUD, error)    { return airSwingUDType.parse(s) }
+func AirSwingUDValues() []AirSwingUD                  { return airSwingUDType.all() }
+
+func (a AirSwingLR) String() string                   { return airSwingLRType.format(a) }
+func (a AirSwingLR) MarshalText() ([]byte, error)     { return airSwingLRType.marshalText(a) }
+func (a *AirSwingLR) UnmarshalText(text []byte) error { return airSwingLRType.unmarshalText(a, text) }
+func (a AirSwingLR) MarshalJSON() ([]byte, error)     { return json.Marshal(int(a)) }
+func (a *AirSwingLR) UnmarshalJSON(data []byte) error { return airSwingLRType.unmarshalJSON(a, data) }
+func ParseAirSwingLR(s string) (AirSwingLR, error)    { return airSwingLRType.parse(s) }
+func AirSwingLRValues() []AirSwingLR                  { return airSwingLRType.all() }
+
+func (m EcoMode) String() string                   { return ecoModeType.format(m) }
+func (m EcoMode) MarshalText() ([]byte, error)     { return ecoModeType.marshalText(m) }
+func (m *EcoMode) UnmarshalText(text []byte) error { return ecoModeType.unmarshalText(m, text) }
+func (m EcoMode) MarshalJSON() ([]byte, error)     { return json.Marshal(int(m)) }
+func (m *EcoMode) UnmarshalJSON(data []byte) error { return ecoModeType.unmarshalJSON(m, data) }
+func ParseEcoMode(s string) (EcoMode, error)       { return ecoModeType.parse(s) }
+func EcoModeValues() []EcoMode                     { return ecoModeType.all() }
+
+func (m AirSwingAutoMode) String() string               { return airSwingAutoModeType.format(m) }
+func (m AirSwingAutoMode) MarshalText() ([]byte, error) { return airSwingAutoModeType.marshalText(m) }
+func (m *AirSwingAutoMode) UnmarshalText(text []byte) error {
+	return airSwingAutoModeType.unmarshalText(m, text)
+}
+func (m AirSwingAutoMode) MarshalJSON() ([]byte, error) { return json.Marshal(int(m)) }
+func (m *AirSwingAutoMode) UnmarshalJSON(data []byte) error {
+	return airSwingAutoModeType.unmarshalJSON(m, data)
+}
+func ParseAirSwingAutoMode(s string) (AirSwingAutoMode, error) { return airSwingAutoModeType.parse(s) }
+func AirSwingAutoModeValues() []AirSwingAutoMode               { return airSwingAutoModeType.all() }
+
+func (f FanSpeed) String() string                   { return fanSpeedType.format(f) }
+func (f FanSpeed) MarshalText() ([]byte, error)     { return fanSpeedType.marshalText(f) }
+func (f *FanSpeed) UnmarshalText(text []byte) error { return fanSpeedType.unmarshalText(f, text) }
+func (f FanSpeed) MarshalJSON() ([]byte, error)     { return json.Marshal(int(f)) }
+func (f *FanSpeed) UnmarshalJSON(data []byte) error { return fanSpeedType.unmarshalJSON(f, data) }
+func ParseFanSpeed(s string) (FanSpeed, error)      { return fanSpeedType.parse(s) }
+func FanSpeedValues() []FanSpeed                    { return fanSpeedType.all() }
+
+func (m DataMode) String() string                   { return dataModeType.format(m) }
+func (m DataMode) MarshalText() ([]byte, error)     { return dataModeType.marshalText(m) }
+func (m *DataMode) UnmarshalText(text []byte) error { return dataModeType.unmarshalText(m, text) }
+func (m DataMode) MarshalJSON() ([]byte, error)     { return json.Marshal(int(m)) }
+func (m *DataMode) UnmarshalJSON(data []byte) error { return dataModeType.unmarshalJSON(m, data) }
+func ParseDataMode(s string) (DataMode, error)      { return dataModeType.parse(s) }
+func DataModeValues() []DataMode                    { return dataModeType.all() }
+
+func (m NanoeMode) String() string                   { return nanoeModeType.format(m) }
+func (m NanoeMode) MarshalText() ([]byte, error)     { return nanoeModeType.marshalText(m) }
+func (m *NanoeMode) UnmarshalText(text []byte) error { return nanoeModeType.unmarshalText(m, text) }
+func (m NanoeMode) MarshalJSON() ([]byte, error)     { return json.Marshal(int(m)) }
+func (m *NanoeMode) UnmarshalJSON(data []byte) error { return nanoeModeType.unmarshalJSON(m, data) }
+func ParseNanoeMode(s string) (NanoeMode, error)     { return nanoeModeType.parse(s) }
+func NanoeModeValues() []NanoeMode                   { return nanoeModeType.all() }
+
+func (u TemperatureUnit) String() string               { return temperatureUnitType.format(u) }
+func (u TemperatureUnit) MarshalText() ([]byte, error) { return temperatureUnitType.marshalText(u) }
+func (u *TemperatureUnit) UnmarshalText(text []byte) error {
+	return temperatureUnitType.unmarshalText(u, text)
+}
+func (u TemperatureUnit) MarshalJSON() ([]byte, error) { return json.Marshal(int(u)) }
+func (u *TemperatureUnit) UnmarshalJSON(data []byte) error {
+	return temperatureUnitType.unmarshalJSON(u, data)
+}
+func ParseTemperatureUnit(s string) (TemperatureUnit, error) { return temperatureUnitType.parse(s) }
+func TemperatureUnitValues() []TemperatureUnit               { return temperatureUnitType.all() }
@@ -0,0 +1,139 @@
+package comfortcloud
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestParseDataMode(t *testing.T) {
+	for input, want := range map[string]DataMode{"Day": DataModeDay, "week": DataModeWeek, " MONTH ": DataModeMonth,
+		"year": DataModeYear, "4": DataModeYear} {
+		got, err := ParseDataMode(input)
+		if err != nil || got != want {
+			t.Errorf("ParseDataMode(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseDataMode("3"); err == nil {
+		t.Error("ParseDataMode accepted the unused value 3")
+	}
+	for name, mode := range DataModeMap {
+		if got, err := ParseDataMode(name); err != nil || got != mode {
+			t.Errorf("ParseDataMode(%q) = %v, %v, want %v as in DataModeMap", name, got, err, mode)
+		}
+	}
+}
+
+func TestEnumStrings(t *testing.T) {
+	tests := []struct {
+		value fmt.Stringer
+		want  string
+	}{
+		{PowerOn, "On"},
+		{PowerOff, "Off"},
+		{Power(7), "Unknown"},
+		{FanSpeedHighMid, "High-mid"},
+		{AirSwingAutoModeAirSwingUD, "Up-down"},
+		{OperationMode(9), "Unknown"},
+	}
+	for _, test := range tests {
+		if got := test.value.String(); got != test.want {
+			t.Errorf("%#v.String() = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+type textEnum interface {
+	~int
+	fmt.Stringer
+	encoding.TextMarshaler
+}
+
+// testTextRoundTrip checks that every value is encoded as the text in texts, that the text decodes to the
+// value again and that JSON keeps using the integer.
+func testTextRoundTrip[T textEnum, P interface {
+	*T
+	encoding.TextUnmarshaler
+}](t *testing.T, values []T, texts []string) {
+	t.Helper()
+	if len(values) != len(texts) {
+		t.Fatalf("got %d values for %d texts", len(values), len(texts))
+	}
+	// A value unknown to the library is encoded as its integer.
+	values, texts = append(values, T(42)), append(texts, "42")
+	for i, value := range values {
+		text, err := value.MarshalText()
+		if err != nil || string(text) != texts[i] {
+			t.Errorf("%v.MarshalText() = %s, %v, want %s", value, text, err, texts[i])
+			continue
+		}
+		var decoded T
+		if err := P(&decoded).UnmarshalText(text); err != nil || decoded != value {
+			t.Errorf("UnmarshalText(%s) = %d, %v, want %d", text, int(decoded), err, int(value))
+		}
+		data, err := json.Marshal(value)
+		if err != nil || string(data) != fmt.Sprint(int(value)) {
+			t.Errorf("json.Marshal(%v) = %s, %v, want %d", value, data, err, int(value))
+		}
+	}
+}
+
+func TestEnumTextRoundTrip(t *testing.T) {
+	t.Run("power", func(t *testing.T) {
+		testTextRoundTrip(t, PowerValues(), []string{"off", "on"})
+	})
+	t.Run("operation mode", func(t *testing.T) {
+		testTextRoundTrip(t, OperationModeValues(), []string{"auto", "dry", "cool", "heat", "fan"})
+	})
+	t.Run("vertical air swing", func(t *testing.T) {
+		testTextRoundTrip(t, AirSwingUDValues(), []string{"auto", "up", "up-mid", "mid", "down-mid", "down", "swing"})
+	})
+	t.Run("horizontal air swing", func(t *testing.T) {
+		testTextRoundTrip(t, AirSwingLRValues(), []string{"auto", "left", "mid", "right-mid", "right"})
+	})
+	t.Run("eco mode", func(t *testing.T) {
+		testTextRoundTrip(t, EcoModeValues(), []string{"auto", "powerful", "quiet"})
+	})
+	t.Run("air swing auto mode", func(t *testing.T) {
+		testTextRoundTrip(t, AirSwingAutoModeValues(), []string{"disabled", "both", "up-down", "left-right"})
+	})
+	t.Run("fan speed", func(t *testing.T) {
+		testTextRoundTrip(t, FanSpeedValues(), []string{"auto", "low", "low-mid", "mid", "high-mid", "high"})
+	})
+	t.Run("data mode", func(t *testing.T) {
+		testTextRoundTrip(t, DataModeValues(), []string{"day", "week", "month", "year"})
+	})
+	t.Run("nanoe mode", func(t *testing.T) {
+		testTextRoundTrip(t, NanoeModeValues(), []string{"unavailable", "off", "on", "mode-g", "all"})
+	})
+	t.Run("temperature unit", func(t *testing.T) {
+		testTextRoundTrip(t, TemperatureUnitValues(), []string{"celsius", "fahrenheit"})
+	})
+}
+
+func TestEnumUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  FanSpeed
+		fail  bool
+	}{
+		{input: `"high-mid"`, want: FanSpeedHighMid},
+		{input: `"High_Mid"`, want: FanSpeedHighMid},
+		{input: `"high mid"`, want: FanSpeedHighMid},
+		{input: `4`, want: FanSpeedHighMid},
+		{input: `9`, want: FanSpeed(9)},
+		{input: `"turbo"`, fail: true},
+		{input: `true`, fail: true},
+	}
+	for _, test := range tests {
+		var speed FanSpeed
+		err := json.Unmarshal([]byte(test.input), &speed)
+		if (err != nil) != test.fail || !test.fail && speed != test.want {
+			t.Errorf("Unmarshal(%s) = %d, %v, want %d", test.input, int(speed), err, int(test.want))
+		}
+	}
+	if _, err := ParseFanSpeed("9"); err == nil {
+		t.Error("ParseFanSpeed accepted the unknown value 9")
+	}
+}
@@ -0,0 +1,411 @@
+package comfortcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultExportBatchSize = 500
+
+// ExportFormat is the encoding written by an Exporter.
+type ExportFormat int
+
+const (
+	// ExportLineProtocol is the InfluxDB line protocol with nanosecond timestamps.
+	ExportLineProtocol ExportFormat = iota
+	// ExportCSV is CSV with a header row, one column per tag and field.
+	ExportCSV
+)
+
+// ExportNaming configures the measurement and tag names of exported points.
+type ExportNaming struct {
+	SnapshotMeasurement string
+	HistoryMeasurement  string
+	DeviceTag           string
+	DeviceNameTag       string
+	DataModeTag         string
+	// Tags are added to every point, e.g. {"site": "office"}.
+	Tags map[string]string
+}
+
+func DefaultExportNaming() ExportNaming {
+	return ExportNaming{
+		SnapshotMeasurement: "comfortcloud_status",
+		HistoryMeasurement:  "comfortcloud_history",
+		DeviceTag:           "device",
+		DeviceNameTag:       "device_name",
+		DataModeTag:         "data_mode",
+	}
+}
+
+// snapshotFields and historyFields are the exported fields in CSV column order.
+var (
+	snapshotFields = []string{"operate", "operation_mode", "temperature_set", "inside_temperature",
+		"out_temperature", "fan_speed", "eco_mode", "air_swing_ud", "air_swing_lr", "nanoe"}
+	historyFields = []string{"consumption", "cost", "average_setting_temp", "average_inside_temp",
+		"average_outside_temp"}
+)
+
+// ExportSink receives the encoded batches of an Exporter.
+type ExportSink interface {
+	WriteBatch(ctx context.Context, batch []byte) error
+}
+
+// WriterSink writes batches to a writer, e.g. os.Stdout.
+type WriterSink struct {
+	Writer io.Writer
+}
+
+func (s WriterSink) WriteBatch(_ context.Context, batch []byte) error {
+	_, err := s.Writer.Write(batch)
+	return err
+}
+
+// FileSink appends batches to a file. When appending CSV to a file that already has content, the header
+// row is not written again.
+type FileSink struct {
+	file    *os.File
+	written bool
+}
+
+func OpenFileSink(fileName string) (*FileSink, error) {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+	return &FileSink{file: file, written: info.Size() > 0}, nil
+}
+
+func (s *FileSink) WriteBatch(_ context.Context, batch []byte) error {
+	_, err := s.file.Write(batch)
+	s.written = true
+	return err
+}
+
+// hasContent reports whether the file already contains exported data.
+func (s *FileSink) hasContent() bool {
+	return s.written
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink POSTs batches to an HTTP endpoint such as the InfluxDB write API,
+// e.g. http://localhost:8086/api/v2/write?org=home&bucket=climate.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+	// Token is sent as "Authorization: Token <Token>" if set.
+	Token string
+	// ContentType defaults to text/plain, as expected by the InfluxDB write API.
+	ContentType string
+}
+
+func (s HTTPSink) WriteBatch(ctx context.Context, batch []byte) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(batch))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export: expected status 2xx, got %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// exportPoint is a single measurement before encoding. Fields that are not set are omitted.
+type exportPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]any
+	time        time.Time
+}
+
+// Exporter encodes device snapshots and history records and writes them to a sink in batches of BatchSize
+// points. Call Flush to write the last partial batch.
+type Exporter struct {
+	Sink      ExportSink
+	Format    ExportFormat
+	Naming    ExportNaming
+	BatchSize int
+	// DeviceNames maps DeviceGuids to the names written to the device name tag.
+	DeviceNames map[string]string
+
+	mu            sync.Mutex
+	pending       []exportPoint
+	headerWritten bool
+}
+
+func NewExporter(sink ExportSink, format ExportFormat) *Exporter {
+	return &Exporter{
+		Sink:      sink,
+		Format:    format,
+		Naming:    DefaultExportNaming(),
+		BatchSize: DefaultExportBatchSize,
+	}
+}
+
+// ExportDevice exports the current parameters of a device.
+func (e *Exporter) ExportDevice(ctx context.Context, device Device, at time.Time) error {
+	e.mu.Lock()
+	if e.DeviceNames == nil {
+		e.DeviceNames = make(map[string]string)
+	}
+	e.DeviceNames[device.DeviceGuid] = device.DeviceName
+	e.mu.Unlock()
+	return e.ExportSnapshot(ctx, Snapshot{DeviceGuid: device.DeviceGuid, Time: at, Parameters: device.Parameters})
+}
+
+// ExportSnapshot exports a snapshot, e.g. one read from a HistoryStore.
+func (e *Exporter) ExportSnapshot(ctx context.Context, snapshot Snapshot) error {
+	p := snapshot.Parameters
+	fields := map[string]any{
+		"operate":        int(p.Operate),
+		"operation_mode": int(p.OperationMode),
+		"fan_speed":      int(p.FanSpeed),
+		"eco_mode":       int(p.EcoMode),
+		"air_swing_ud":   int(p.AirSwingUD),
+		"air_swing_lr":   int(p.AirSwingLR),
+		"nanoe":          int(p.Nanoe),
+	}
+	addTemperatureField(fields, "temperature_set", p.TemperatureSet)
+	addTemperatureField(fields, "inside_temperature", p.InsideTemperature)
+	addTemperatureField(fields, "out_temperature", p.OutTemperature)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	point := exportPoint{
+		measurement: e.Naming.SnapshotMeasurement,
+		tags:        e.deviceTags(snapshot.DeviceGuid),
+		fields:      fields,
+		time:        snapshot.Time,
+	}
+	return e.addLocked(ctx, point)
+}
+
+// ExportHistory exports the history records of a device. Records without data are skipped.
+func (e *Exporter) ExportHistory(ctx context.Context, deviceGuid string, mode DataMode, records []HistoryRecord) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		if !record.HasData() {
+			continue
+		}
+		fields := map[string]any{"consumption": record.Consumption, "cost": record.Cost}
+		addTemperatureField(fields, "average_setting_temp", record.AverageSettingTemp)
+		addTemperatureField(fields, "average_inside_temp", record.AverageInsideTemp)
+		addTemperatureField(fields, "average_outside_temp", record.AverageOutsideTemp)
+
+		tags := e.deviceTags(deviceGuid)
+		if e.Naming.DataModeTag != "" {
+			name, _ := mode.MarshalText()
+			tags[e.Naming.DataModeTag] = string(name)
+		}
+		point := exportPoint{measurement: e.Naming.HistoryMeasurement, tags: tags, fields: fields, time: record.Start}
+		if err := e.addLocked(ctx, point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addTemperatureField(fields map[string]any, name string, temperature Temperature) {
+	if temperature.Available() {
+		fields[name] = temperature.Celsius()
+	}
+}
+
+// deviceTags returns the tags of a point of the device. The caller holds e.mu.
+func (e *Exporter) deviceTags(deviceGuid string) map[string]string {
+	tags := make(map[string]string, len(e.Naming.Tags)+3)
+	for key, value := range e.Naming.Tags {
+		tags[key] = value
+	}
+	if e.Naming.DeviceTag != "" {
+		tags[e.Naming.DeviceTag] = deviceGuid
+	}
+	if name := e.DeviceNames[deviceGuid]; name != "" && e.Naming.DeviceNameTag != "" {
+		tags[e.Naming.DeviceNameTag] = name
+	}
+	return tags
+}
+
+// addLocked queues a point and writes the batch once it is full. The caller holds e.mu.
+func (e *Exporter) addLocked(ctx context.Context, point exportPoint) error {
+	e.pending = append(e.pending, point)
+	if e.BatchSize > 0 && len(e.pending) < e.BatchSize {
+		return nil
+	}
+	return e.flushLocked(ctx)
+}
+
+// Flush writes the queued points.
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.flushLocked(ctx)
+}
+
+func (e *Exporter) flushLocked(ctx context.Context) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	var batch []byte
+	var err error
+	switch e.Format {
+	case ExportLineProtocol:
+		batch = encodeLineProtocol(e.pending)
+	case ExportCSV:
+		header := !e.headerWritten
+		if sink, ok := e.Sink.(interface{ hasContent() bool }); ok && sink.hasContent() {
+			header = false
+		}
+		batch, err = e.encodeCSV(e.pending, header)
+	default:
+		err = fmt.Errorf("unknown export format %d", e.Format)
+	}
+	if err != nil {
+		return err
+	}
+	if err := e.Sink.WriteBatch(ctx, batch); err != nil {
+		return fmt.Errorf("failed to write export batch: %w", err)
+	}
+	e.pending = e.pending[:0]
+	e.headerWritten = true
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+	fieldStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+func encodeLineProtocol(points []exportPoint) []byte {
+	var buf bytes.Buffer
+	for _, point := range points {
+		buf.WriteString(measurementEscaper.Replace(point.measurement))
+		for _, key := range sortedKeys(point.tags) {
+			if point.tags[key] == "" {
+				continue
+			}
+			buf.WriteByte(',')
+			buf.WriteString(tagEscaper.Replace(key))
+			buf.WriteByte('=')
+			buf.WriteString(tagEscaper.Replace(point.tags[key]))
+		}
+		for i, key := range sortedKeys(point.fields) {
+			if i == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(tagEscaper.Replace(key))
+			buf.WriteByte('=')
+			switch value := point.fields[key].(type) {
+			case int:
+				buf.WriteString(strconv.Itoa(value))
+				buf.WriteByte('i')
+			case float64:
+				buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+			default:
+				buf.WriteByte('"')
+				buf.WriteString(fieldStringEscaper.Replace(fmt.Sprint(value)))
+				buf.WriteByte('"')
+			}
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(point.time.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// csvTagColumns returns the tag columns of the CSV format. The caller holds e.mu.
+func (e *Exporter) csvTagColumns() []string {
+	columns := sortedKeys(e.Naming.Tags)
+	for _, tag := range []string{e.Naming.DeviceTag, e.Naming.DeviceNameTag, e.Naming.DataModeTag} {
+		if tag != "" {
+			columns = append(columns, tag)
+		}
+	}
+	return columns
+}
+
+func (e *Exporter) encodeCSV(points []exportPoint, header bool) ([]byte, error) {
+	tagColumns := e.csvTagColumns()
+	fieldColumns := append(append([]string(nil), snapshotFields...), historyFields...)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if header {
+		row := append([]string{"measurement", "time"}, tagColumns...)
+		if err := writer.Write(append(row, fieldColumns...)); err != nil {
+			return nil, err
+		}
+	}
+	for _, point := range points {
+		row := []string{point.measurement, point.time.Format(time.RFC3339)}
+		for _, tag := range tagColumns {
+			row = append(row, point.tags[tag])
+		}
+		for _, field := range fieldColumns {
+			value, ok := point.fields[field]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, fmt.Sprint(value))
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to encode CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
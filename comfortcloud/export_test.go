@@ -0,0 +1,117 @@
+package comfortcloud
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeLineProtocolEscaping(t *testing.T) {
+	points := []exportPoint{{
+		measurement: "climate status,v2",
+		tags:        map[string]string{"device name": "Living room, west=1", "empty": ""},
+		fields:      map[string]any{"mode": 2, "temp": 21.5, "note": `say "hi" \o/`},
+		time:        time.Unix(1, 5),
+	}}
+	want := `climate\ status\,v2,device\ name=Living\ room\,\ west\=1 mode=2i,note="say \"hi\" \\o/",temp=21.5 1000000005` + "\n"
+	if got := string(encodeLineProtocol(points)); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func testSnapshot(at time.Time) Snapshot {
+	return Snapshot{DeviceGuid: "guid-1", Time: at, Parameters: Parameters{
+		Operate: PowerOn, TemperatureSet: Celsius(21), InsideTemperature: Celsius(19.5),
+	}}
+}
+
+func TestExportCSVHeaderWrittenOnce(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewExporter(WriterSink{Writer: &buf}, ExportCSV)
+	exporter.BatchSize = 1
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := exporter.ExportSnapshot(ctx, testSnapshot(testNow.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exporter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "measurement,time,") {
+		t.Errorf("got %d lines, want a header and 3 rows:\n%s", len(lines), buf.String())
+	}
+}
+
+func TestFileSinkCSVAppendSkipsHeader(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "export.csv")
+	ctx := context.Background()
+	for run := 0; run < 2; run++ {
+		sink, err := OpenFileSink(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exporter := NewExporter(sink, ExportCSV)
+		if err := exporter.ExportSnapshot(ctx, testSnapshot(testNow.Add(time.Duration(run)*time.Hour))); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers := strings.Count(string(data), "measurement,time,"); headers != 1 {
+		t.Errorf("got %d header rows, want 1:\n%s", headers, data)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("got %d lines, want 3:\n%s", lines, data)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var body, contentType, authorization string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, contentType, authorization = string(data), r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("bucket not found"))
+	}))
+	defer server.Close()
+
+	sink := HTTPSink{URL: server.URL + "/api/v2/write?bucket=climate", Token: "secret"}
+	exporter := NewExporter(sink, ExportLineProtocol)
+	ctx := context.Background()
+	if err := exporter.ExportSnapshot(ctx, testSnapshot(testNow)); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, "comfortcloud_status,device=guid-1 ") {
+		t.Errorf("unexpected body %q", body)
+	}
+	if contentType != "text/plain; charset=utf-8" || authorization != "Token secret" {
+		t.Errorf("Content-Type %q, Authorization %q", contentType, authorization)
+	}
+
+	status = http.StatusNotFound
+	err := sink.WriteBatch(ctx, []byte("m f=1i 0\n"))
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "bucket not found") {
+		t.Errorf("got %v, want the status and body of the failed request", err)
+	}
+}
@@ -0,0 +1,156 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var jsonFieldNameCache sync.Map // reflect.Type -> map[string]bool
+
+// jsonFieldNames returns the lower-cased JSON names of the fields of a struct type.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	if names, ok := jsonFieldNameCache.Load(t); ok {
+		return names.(map[string]bool)
+	}
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	jsonFieldNameCache.Store(t, names)
+	return names
+}
+
+// decodeWithExtra decodes data into v, a pointer to a struct without its own UnmarshalJSON, and
+// merges the fields the struct does not know into extra. extra itself is not modified.
+func decodeWithExtra(data []byte, v any, extra map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	known := jsonFieldNames(reflect.TypeOf(v).Elem())
+	var merged map[string]json.RawMessage
+	for key, value := range fields {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]json.RawMessage, len(extra)+1)
+			for k, v := range extra {
+				merged[k] = v
+			}
+		}
+		merged[key] = value
+	}
+	if merged == nil {
+		return extra, nil
+	}
+	return merged, nil
+}
+
+// encodeWithExtra encodes v, a struct without its own MarshalJSON, and adds the extra fields
+// that do not collide with the fields of the struct.
+func encodeWithExtra(v any, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (d *Device) UnmarshalJSON(data []byte) error {
+	type device Device
+	extra, err := decodeWithExtra(data, (*device)(d), d.Extra)
+	if err != nil {
+		return err
+	}
+	d.Extra = extra
+	return nil
+}
+
+func (d Device) MarshalJSON() ([]byte, error) {
+	type device Device
+	return encodeWithExtra(device(d), d.Extra)
+}
+
+func (p *Parameters) UnmarshalJSON(data []byte) error {
+	type parameters Parameters
+	extra, err := decodeWithExtra(data, (*parameters)(p), p.Extra)
+	if err != nil {
+		return err
+	}
+	p.Extra = extra
+	return nil
+}
+
+func (p Parameters) MarshalJSON() ([]byte, error) {
+	type parameters Parameters
+	return encodeWithExtra(parameters(p), p.Extra)
+}
+
+// UnknownFields lists the fields returned by the API that the library does not understand yet,
+// with nested parameters prefixed by "parameters.".
+func (d *Device) UnknownFields() []string {
+	var fields []string
+	for key := range d.Extra {
+		fields = append(fields, key)
+	}
+	for key := range d.Parameters.Extra {
+		fields = append(fields, "parameters."+key)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// clone returns a copy of the device that shares no maps or slices with d.
+func (d Device) clone() Device {
+	d.Extra = cloneExtra(d.Extra)
+	d.Parameters.Extra = cloneExtra(d.Parameters.Extra)
+	d.Raw = cloneRawMessage(d.Raw)
+	return d
+}
+
+func cloneExtra(extra map[string]json.RawMessage) map[string]json.RawMessage {
+	if extra == nil {
+		return nil
+	}
+	cloned := make(map[string]json.RawMessage, len(extra))
+	for key, value := range extra {
+		cloned[key] = cloneRawMessage(value)
+	}
+	return cloned
+}
+
+func cloneRawMessage(message json.RawMessage) json.RawMessage {
+	if message == nil {
+		return nil
+	}
+	return append(json.RawMessage(nil), message...)
+}
@@ -0,0 +1,116 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const deviceWithUnknownFields = `{
+	"deviceGuid": "guid-1",
+	"deviceName": "Living room",
+	"newFeatureFlg": true,
+	"schedule": {"enabled": false, "slots": [1, 2]},
+	"parameters": {
+		"operate": 1,
+		"temperatureSet": 21.5,
+		"airQuality": 2,
+		"humidity": 45,
+		"ecoNaviMode": [0, 1]
+	}
+}`
+
+func TestUnknownFieldsRoundTrip(t *testing.T) {
+	var device Device
+	if err := json.Unmarshal([]byte(deviceWithUnknownFields), &device); err != nil {
+		t.Fatal(err)
+	}
+	if device.DeviceName != "Living room" || device.Parameters.Operate != PowerOn ||
+		device.Parameters.TemperatureSet != Celsius(21.5) || device.Parameters.AirQuality != 2 {
+		t.Errorf("known fields not decoded: %+v", device)
+	}
+
+	data, err := json.Marshal(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var original, encoded map[string]any
+	if err := json.Unmarshal([]byte(deviceWithUnknownFields), &original); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"newFeatureFlg", "schedule"} {
+		if !reflect.DeepEqual(encoded[key], original[key]) {
+			t.Errorf("%s = %v after round trip, want %v", key, encoded[key], original[key])
+		}
+	}
+	parameters, originalParameters := encoded["parameters"].(map[string]any), original["parameters"].(map[string]any)
+	for _, key := range []string{"humidity", "ecoNaviMode", "operate", "temperatureSet"} {
+		if !reflect.DeepEqual(parameters[key], originalParameters[key]) {
+			t.Errorf("parameters.%s = %v after round trip, want %v", key, parameters[key], originalParameters[key])
+		}
+	}
+}
+
+func TestUnknownFieldsDoNotOverrideKnownFields(t *testing.T) {
+	device := Device{DeviceGuid: "guid-1", DeviceName: "Living room",
+		Extra: map[string]json.RawMessage{"deviceName": json.RawMessage(`"stale"`), "new": json.RawMessage(`1`)}}
+	data, err := json.Marshal(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Device
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.DeviceName != "Living room" || string(decoded.Extra["new"]) != "1" {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "none", data: `{"deviceGuid":"guid-1","parameters":{"operate":1}}`},
+		{name: "device and parameters", data: deviceWithUnknownFields,
+			want: []string{"newFeatureFlg", "parameters.ecoNaviMode", "parameters.humidity", "schedule"}},
+		// Field names are matched case-insensitively, like encoding/json does.
+		{name: "case-insensitive", data: `{"DeviceGUID":"guid-1","parameters":{"Operate":1}}`},
+		// Fields without a JSON name are not decoded, so their names are unknown API fields.
+		{name: "ignored fields", data: `{"online":true,"raw":"x","extra":{}}`, want: []string{"extra", "online", "raw"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var device Device
+			if err := json.Unmarshal([]byte(test.data), &device); err != nil {
+				t.Fatal(err)
+			}
+			if got := device.UnknownFields(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("UnknownFields() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnknownFieldsMergedOnDecode(t *testing.T) {
+	// A status decoded into a device from the listing keeps the unknown fields of the listing.
+	var device Device
+	if err := json.Unmarshal([]byte(`{"deviceGuid":"guid-1","listingOnly":1}`), &device); err != nil {
+		t.Fatal(err)
+	}
+	listing := device.Extra
+	if err := json.Unmarshal([]byte(`{"deviceGuid":"guid-1","statusOnly":2}`), &device); err != nil {
+		t.Fatal(err)
+	}
+	if got := device.UnknownFields(); !reflect.DeepEqual(got, []string{"listingOnly", "statusOnly"}) {
+		t.Errorf("UnknownFields() = %v", got)
+	}
+	if len(listing) != 1 {
+		t.Errorf("decoding modified the previous extra fields: %v", listing)
+	}
+}
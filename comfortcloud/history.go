@@ -0,0 +1,98 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// History is the aggregated consumption history of a device for one period.
+type History struct {
+	DeviceGuid        string          `json:"deviceGuid"`
+	DataMode          DataMode        `json:"dataMode"`
+	Date              time.Time       `json:"-"`
+	EnergyConsumption float64         `json:"energyConsumption"`
+	EstimatedCost     float64         `json:"estimatedCost"`
+	CurrencyUnit      string          `json:"currencyUnit"`
+	HistoryDataList   []HistoryRecord `json:"historyDataList"`
+}
+
+// HistoryRecord is one bucket of the history: an hour for DataModeDay, a day for DataModeWeek and
+// DataModeMonth and a month for DataModeYear. Start and End are derived from the requested date.
+type HistoryRecord struct {
+	DataNumber         int         `json:"dataNumber"`
+	Consumption        float64     `json:"consumption"`
+	Cost               float64     `json:"cost"`
+	AverageSettingTemp Temperature `json:"averageSettingTemp"`
+	AverageInsideTemp  Temperature `json:"averageInsideTemp"`
+	AverageOutsideTemp Temperature `json:"averageOutsideTemp"`
+	Start              time.Time   `json:"start"`
+	End                time.Time   `json:"end"`
+}
+
+// HasData reports whether the API returned a measurement for the bucket.
+func (r HistoryRecord) HasData() bool {
+	return r.Consumption != historyValueUnavailable
+}
+
+// GetDeviceHistory fetches the history of a device for the period containing date. The location of date
+// is sent as the time zone of the request.
+func (c *Client) GetDeviceHistory(deviceID string, mode DataMode, date time.Time) (*History, error) {
+	device, err := c.ResolveDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ensureLoggedIn(); err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"deviceGuid": device.DeviceGuid,
+		"dataMode":   mode,
+		"date":       date.Format("20060102"),
+		"osTimezone": date.Format("-07:00"),
+	}
+	response, err := c.auth.ExecutePost(c.getDeviceHistoryURL(), payload, "get_device_history", http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch device history: %w", err)
+	}
+
+	history := History{DeviceGuid: device.DeviceGuid, DataMode: mode, Date: date}
+	if err := json.Unmarshal(response, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse device history: %w", err)
+	}
+	for i := range history.HistoryDataList {
+		record := &history.HistoryDataList[i]
+		record.Start, record.End = historyRecordPeriod(mode, date, record.DataNumber)
+	}
+	return &history, nil
+}
+
+// historyWeekStart is the first day of the weeks returned for DataModeWeek, as shown in the app.
+const historyWeekStart = time.Sunday
+
+// historyRecordPeriod returns the period of the bucket dataNumber of the history containing date. Buckets
+// are local wall clock hours, days and months: on a DST change, the bucket of the skipped hour is empty
+// and the bucket of the repeated hour lasts two hours.
+func historyRecordPeriod(mode DataMode, date time.Time, dataNumber int) (time.Time, time.Time) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	switch mode {
+	case DataModeDay:
+		start := time.Date(day.Year(), day.Month(), day.Day(), dataNumber, 0, 0, 0, day.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), dataNumber+1, 0, 0, 0, day.Location())
+		return start, end
+	case DataModeWeek:
+		weekStart := day.AddDate(0, 0, -int((day.Weekday()-historyWeekStart+7)%7))
+		start := weekStart.AddDate(0, 0, dataNumber)
+		return start, start.AddDate(0, 0, 1)
+	case DataModeMonth:
+		start := time.Date(date.Year(), date.Month(), 1+dataNumber, 0, 0, 0, 0, date.Location())
+		return start, start.AddDate(0, 0, 1)
+	case DataModeYear:
+		start := time.Date(date.Year(), time.January+time.Month(dataNumber), 1, 0, 0, 0, 0, date.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
@@ -0,0 +1,407 @@
+package comfortcloud
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historyStoreSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	device_guid        TEXT    NOT NULL,
+	recorded_at        INTEGER NOT NULL,
+	operate            INTEGER NOT NULL,
+	operation_mode     INTEGER NOT NULL,
+	temperature_set    REAL,
+	inside_temperature REAL,
+	out_temperature    REAL,
+	fan_speed          INTEGER NOT NULL,
+	parameters         TEXT    NOT NULL,
+	PRIMARY KEY (device_guid, recorded_at)
+);
+CREATE TABLE IF NOT EXISTS history (
+	device_guid          TEXT    NOT NULL,
+	data_mode            INTEGER NOT NULL,
+	start                INTEGER NOT NULL,
+	end                  INTEGER NOT NULL,
+	consumption          REAL,
+	cost                 REAL,
+	average_setting_temp REAL,
+	average_inside_temp  REAL,
+	average_outside_temp REAL,
+	recorded_at          INTEGER NOT NULL,
+	PRIMARY KEY (device_guid, data_mode, start)
+);
+CREATE INDEX IF NOT EXISTS snapshots_recorded_at ON snapshots (recorded_at);
+CREATE INDEX IF NOT EXISTS history_start ON history (start);
+`
+
+// Metric is a value that can be queried from a HistoryStore.
+type Metric string
+
+const (
+	MetricOperate           Metric = "operate"
+	MetricOperationMode     Metric = "operation_mode"
+	MetricTemperatureSet    Metric = "temperature_set"
+	MetricInsideTemperature Metric = "inside_temperature"
+	MetricOutTemperature    Metric = "out_temperature"
+	MetricFanSpeed          Metric = "fan_speed"
+	MetricConsumption       Metric = "consumption"
+	MetricCost              Metric = "cost"
+)
+
+// snapshotMetrics and historyMetrics map metrics to the table column they are stored in.
+var (
+	snapshotMetrics = map[Metric]string{
+		MetricOperate:           "operate",
+		MetricOperationMode:     "operation_mode",
+		MetricTemperatureSet:    "temperature_set",
+		MetricInsideTemperature: "inside_temperature",
+		MetricOutTemperature:    "out_temperature",
+		MetricFanSpeed:          "fan_speed",
+	}
+	historyMetrics = map[Metric]string{
+		MetricConsumption: "consumption",
+		MetricCost:        "cost",
+	}
+)
+
+// HistoryStore is a local SQLite database of device status snapshots and history buckets. It keeps the
+// history beyond the periods returned by the cloud.
+type HistoryStore struct {
+	db    *sql.DB
+	clock Clock
+}
+
+// Snapshot is the status of a device at the time it was recorded.
+type Snapshot struct {
+	DeviceGuid string
+	Time       time.Time
+	Parameters Parameters
+}
+
+// Sample is a single value of a metric.
+type Sample struct {
+	DeviceGuid string
+	Time       time.Time
+	Value      float64
+}
+
+// SampleQuery selects samples of a metric. An empty DeviceGuid selects all devices, zero times are
+// unbounded. DataMode selects the bucket size of history metrics.
+type SampleQuery struct {
+	DeviceGuid string
+	Metric     Metric
+	DataMode   DataMode
+	From       time.Time
+	To         time.Time
+}
+
+// RetentionPolicy is the maximum age of the records in a HistoryStore. Zero keeps records forever.
+type RetentionPolicy struct {
+	Snapshots time.Duration
+	History   time.Duration
+}
+
+// OpenHistoryStore opens or creates the SQLite database fileName.
+func OpenHistoryStore(fileName string) (*HistoryStore, error) {
+	db, err := sql.Open("sqlite", fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %w", err)
+	}
+	// SQLite allows a single writer, serializing all access avoids SQLITE_BUSY errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historyStoreSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history store schema: %w", err)
+	}
+	return &HistoryStore{db: db, clock: systemClock{}}, nil
+}
+
+func (s *HistoryStore) Close() error {
+	return s.db.Close()
+}
+
+// nullableTemperature stores unavailable temperatures as NULL.
+func nullableTemperature(t Temperature) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: t.Celsius(), Valid: t.Available()}
+}
+
+// RecordSnapshot stores the parameters of a device. The snapshot is keyed by the status timestamp of the
+// device, or by at if the device has none, so a status that was served again from a cache is stored once.
+// A second snapshot of the same device within the same second is ignored.
+func (s *HistoryStore) RecordSnapshot(ctx context.Context, device Device, at time.Time) error {
+	if device.Timestamp > 0 {
+		at = time.UnixMilli(device.Timestamp)
+	}
+	parameters, err := json.Marshal(device.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+	p := device.Parameters
+	_, err = s.db.ExecContext(ctx, `INSERT OR IGNORE INTO snapshots (device_guid, recorded_at, operate,
+		operation_mode, temperature_set, inside_temperature, out_temperature, fan_speed, parameters)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		device.DeviceGuid, at.Unix(), int(p.Operate), int(p.OperationMode), nullableTemperature(p.TemperatureSet),
+		nullableTemperature(p.InsideTemperature), nullableTemperature(p.OutTemperature), int(p.FanSpeed),
+		string(parameters))
+	if err != nil {
+		return fmt.Errorf("failed to record snapshot: %w", err)
+	}
+	return nil
+}
+
+// RecordHistory stores the buckets of a history. Buckets that were recorded before are replaced, so the
+// growing bucket of the current period always has its latest value.
+func (s *HistoryStore) RecordHistory(ctx context.Context, history *History) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.clock.Now().Unix()
+	for _, record := range history.HistoryDataList {
+		if !record.HasData() {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO history (device_guid, data_mode, start, end, consumption, cost,
+			average_setting_temp, average_inside_temp, average_outside_temp, recorded_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (device_guid, data_mode, start) DO UPDATE SET end = excluded.end,
+			consumption = excluded.consumption, cost = excluded.cost,
+			average_setting_temp = excluded.average_setting_temp, average_inside_temp = excluded.average_inside_temp,
+			average_outside_temp = excluded.average_outside_temp, recorded_at = excluded.recorded_at`,
+			history.DeviceGuid, int(history.DataMode), record.Start.Unix(), record.End.Unix(), record.Consumption,
+			record.Cost, nullableTemperature(record.AverageSettingTemp), nullableTemperature(record.AverageInsideTemp),
+			nullableTemperature(record.AverageOutsideTemp), now)
+		if err != nil {
+			return fmt.Errorf("failed to record history: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// timeRange returns the SQL condition and arguments restricting column to [from, to).
+func timeRange(column string, from, to time.Time) (string, []any) {
+	condition := ""
+	var args []any
+	if !from.IsZero() {
+		condition += " AND " + column + " >= ?"
+		args = append(args, from.Unix())
+	}
+	if !to.IsZero() {
+		condition += " AND " + column + " < ?"
+		args = append(args, to.Unix())
+	}
+	return condition, args
+}
+
+// QuerySamples returns the samples of a metric ordered by time.
+func (s *HistoryStore) QuerySamples(ctx context.Context, query SampleQuery) ([]Sample, error) {
+	var statement string
+	var args []any
+	if column, ok := snapshotMetrics[query.Metric]; ok {
+		statement = "SELECT device_guid, recorded_at, " + column + " FROM snapshots WHERE " + column + " IS NOT NULL"
+		condition, rangeArgs := timeRange("recorded_at", query.From, query.To)
+		statement += condition
+		args = append(args, rangeArgs...)
+	} else if column, ok := historyMetrics[query.Metric]; ok {
+		statement = "SELECT device_guid, start, " + column + " FROM history WHERE data_mode = ?"
+		args = append(args, int(query.DataMode))
+		condition, rangeArgs := timeRange("start", query.From, query.To)
+		statement += condition
+		args = append(args, rangeArgs...)
+	} else {
+		return nil, fmt.Errorf("unknown metric %q", query.Metric)
+	}
+	if query.DeviceGuid != "" {
+		statement += " AND device_guid = ?"
+		args = append(args, query.DeviceGuid)
+	}
+	statement += " ORDER BY 2, 1"
+
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query samples: %w", err)
+	}
+	defer rows.Close()
+	var samples []Sample
+	for rows.Next() {
+		var sample Sample
+		var unix int64
+		if err := rows.Scan(&sample.DeviceGuid, &unix, &sample.Value); err != nil {
+			return nil, fmt.Errorf("failed to read sample: %w", err)
+		}
+		sample.Time = time.Unix(unix, 0)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+// QuerySnapshots returns the snapshots of a device in [from, to) ordered by time. An empty deviceGuid
+// selects all devices.
+func (s *HistoryStore) QuerySnapshots(ctx context.Context, deviceGuid string, from, to time.Time) ([]Snapshot, error) {
+	statement := "SELECT device_guid, recorded_at, parameters FROM snapshots WHERE 1 = 1"
+	condition, args := timeRange("recorded_at", from, to)
+	statement += condition
+	if deviceGuid != "" {
+		statement += " AND device_guid = ?"
+		args = append(args, deviceGuid)
+	}
+	rows, err := s.db.QueryContext(ctx, statement+" ORDER BY recorded_at, device_guid", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var unix int64
+		var parameters string
+		if err := rows.Scan(&snapshot.DeviceGuid, &unix, &parameters); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if err := json.Unmarshal([]byte(parameters), &snapshot.Parameters); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+		}
+		snapshot.Time = time.Unix(unix, 0)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// QueryHistory returns the stored history buckets of a device with the given bucket size that start in
+// [from, to), ordered by time.
+func (s *HistoryStore) QueryHistory(ctx context.Context, deviceGuid string, mode DataMode, from, to time.Time) ([]HistoryRecord, error) {
+	statement := `SELECT start, end, consumption, cost, average_setting_temp, average_inside_temp,
+		average_outside_temp FROM history WHERE device_guid = ? AND data_mode = ?`
+	args := []any{deviceGuid, int(mode)}
+	condition, rangeArgs := timeRange("start", from, to)
+	rows, err := s.db.QueryContext(ctx, statement+condition+" ORDER BY start", append(args, rangeArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+	var records []HistoryRecord
+	for rows.Next() {
+		var record HistoryRecord
+		var start, end int64
+		var setting, inside, outside sql.NullFloat64
+		if err := rows.Scan(&start, &end, &record.Consumption, &record.Cost, &setting, &inside, &outside); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		record.Start, record.End = time.Unix(start, 0), time.Unix(end, 0)
+		record.AverageSettingTemp = storedTemperature(setting)
+		record.AverageInsideTemp = storedTemperature(inside)
+		record.AverageOutsideTemp = storedTemperature(outside)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func storedTemperature(value sql.NullFloat64) Temperature {
+	if !value.Valid {
+		return Celsius(historyValueUnavailable)
+	}
+	return Celsius(value.Float64)
+}
+
+// Prune deletes the records older than the retention policy allows and returns the number of deleted rows.
+func (s *HistoryStore) Prune(ctx context.Context, policy RetentionPolicy) (int64, error) {
+	now := s.clock.Now()
+	var deleted int64
+	prune := func(statement string, maxAge time.Duration) error {
+		if maxAge <= 0 {
+			return nil
+		}
+		result, err := s.db.ExecContext(ctx, statement, now.Add(-maxAge).Unix())
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		deleted += n
+		return err
+	}
+	if err := prune("DELETE FROM snapshots WHERE recorded_at < ?", policy.Snapshots); err != nil {
+		return deleted, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	if err := prune("DELETE FROM history WHERE end <= ?", policy.History); err != nil {
+		return deleted, fmt.Errorf("failed to prune history: %w", err)
+	}
+	return deleted, nil
+}
+
+// Recorder periodically stores the status and history of all devices of a client in a HistoryStore.
+type Recorder struct {
+	Client *Client
+	Store  *HistoryStore
+	// Interval is the time between two recordings of Run.
+	Interval time.Duration
+	// HistoryModes are the history bucket sizes that are recorded, e.g. DataModeDay for hourly buckets.
+	HistoryModes []DataMode
+	Retention    RetentionPolicy
+}
+
+// RecordOnce records the current status and the history of the current period of every device and
+// applies the retention policy. Failures of single devices are logged and returned joined.
+func (r *Recorder) RecordOnce(ctx context.Context) error {
+	statuses, err := r.Client.GetAllStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	now := r.Client.clock.Now()
+
+	var errs []error
+	for guid, status := range statuses {
+		if status.Err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", guid, status.Err))
+			continue
+		}
+		if err := r.Store.RecordSnapshot(ctx, *status.Device, now); err != nil {
+			errs = append(errs, err)
+		}
+		for _, mode := range r.HistoryModes {
+			history, err := r.Client.GetDeviceHistory(guid, mode, now)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("device %s: %w", guid, err))
+				continue
+			}
+			if err := r.Store.RecordHistory(ctx, history); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if _, err := r.Store.Prune(ctx, r.Retention); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Run calls RecordOnce every Interval until ctx is done.
+func (r *Recorder) Run(ctx context.Context) error {
+	if r.Interval <= 0 {
+		return errors.New("recorder interval must be positive")
+	}
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.RecordOnce(ctx); err != nil {
+			slog.Warn("Recording device history failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
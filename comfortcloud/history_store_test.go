@@ -0,0 +1,44 @@
+package comfortcloud
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordSnapshotKeyedByStatusTimestamp(t *testing.T) {
+	store, err := OpenHistoryStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	statusTime := testNow.Add(-5 * time.Minute)
+	device := Device{DeviceGuid: "guid-1", Timestamp: statusTime.UnixMilli(),
+		Parameters: Parameters{Operate: PowerOn, TemperatureSet: Celsius(21)}}
+	// The same status polled twice, e.g. served from the status cache
+	for _, at := range []time.Time{testNow, testNow.Add(time.Minute)} {
+		if err := store.RecordSnapshot(ctx, device, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A status without timestamp is recorded at the poll time
+	device.Timestamp = 0
+	if err := store.RecordSnapshot(ctx, device, testNow.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := store.QuerySnapshots(ctx, "guid-1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(snapshots))
+	}
+	if !snapshots[0].Time.Equal(statusTime) || !snapshots[1].Time.Equal(testNow.Add(2*time.Minute)) {
+		t.Errorf("snapshot times %s, %s, want %s, %s", snapshots[0].Time, snapshots[1].Time,
+			statusTime, testNow.Add(2*time.Minute))
+	}
+}
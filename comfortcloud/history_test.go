@@ -0,0 +1,60 @@
+package comfortcloud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available:", err)
+	}
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, berlin)
+	}
+	tests := []struct {
+		name       string
+		mode       DataMode
+		date       time.Time
+		dataNumber int
+		start, end time.Time
+	}{
+		{name: "hour", mode: DataModeDay, date: at(2024, 3, 15, 14), dataNumber: 5,
+			start: at(2024, 3, 15, 5), end: at(2024, 3, 15, 6)},
+		{name: "hour after spring forward", mode: DataModeDay, date: at(2024, 3, 31, 12), dataNumber: 3,
+			start: at(2024, 3, 31, 3), end: at(2024, 3, 31, 4)},
+		{name: "skipped hour", mode: DataModeDay, date: at(2024, 3, 31, 12), dataNumber: 2,
+			start: at(2024, 3, 31, 3), end: at(2024, 3, 31, 3)},
+		{name: "hour after fall back", mode: DataModeDay, date: at(2024, 10, 27, 12), dataNumber: 5,
+			start: at(2024, 10, 27, 5), end: at(2024, 10, 27, 6)},
+		{name: "repeated hour", mode: DataModeDay, date: at(2024, 10, 27, 12), dataNumber: 1,
+			start: at(2024, 10, 27, 1), end: at(2024, 10, 27, 2)},
+		// 2024-03-15 is a Friday, the week starts on Sunday 2024-03-10.
+		{name: "first day of week", mode: DataModeWeek, date: at(2024, 3, 15, 14), dataNumber: 0,
+			start: at(2024, 3, 10, 0), end: at(2024, 3, 11, 0)},
+		{name: "day of week", mode: DataModeWeek, date: at(2024, 3, 15, 14), dataNumber: 5,
+			start: at(2024, 3, 15, 0), end: at(2024, 3, 16, 0)},
+		{name: "week requested on sunday", mode: DataModeWeek, date: at(2024, 3, 10, 0), dataNumber: 6,
+			start: at(2024, 3, 16, 0), end: at(2024, 3, 17, 0)},
+		{name: "week across spring forward", mode: DataModeWeek, date: at(2024, 4, 2, 12), dataNumber: 1,
+			start: at(2024, 4, 1, 0), end: at(2024, 4, 2, 0)},
+		{name: "day of month", mode: DataModeMonth, date: at(2024, 3, 15, 14), dataNumber: 30,
+			start: at(2024, 3, 31, 0), end: at(2024, 4, 1, 0)},
+		{name: "month", mode: DataModeYear, date: at(2024, 3, 15, 14), dataNumber: 9,
+			start: at(2024, 10, 1, 0), end: at(2024, 11, 1, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end := historyRecordPeriod(test.mode, test.date, test.dataNumber)
+			if !start.Equal(test.start) || !end.Equal(test.end) {
+				t.Errorf("period = %s - %s, want %s - %s", start, end, test.start, test.end)
+			}
+		})
+	}
+
+	start, end := historyRecordPeriod(DataModeDay, at(2024, 10, 27, 12), 1)
+	if end.Sub(start) != 2*time.Hour {
+		t.Errorf("repeated hour lasts %s, want 2h", end.Sub(start))
+	}
+}
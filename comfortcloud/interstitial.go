@@ -0,0 +1,262 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const maxLoginRedirects = 10
+
+// InterstitialKind is a page of the Panasonic ID login flow that needs user interaction.
+type InterstitialKind int
+
+const (
+	InterstitialUnknown InterstitialKind = iota
+	InterstitialMFA
+	InterstitialConsent
+	InterstitialTermsOfService
+	InterstitialCaptcha
+)
+
+func (k InterstitialKind) String() string {
+	switch k {
+	case InterstitialMFA:
+		return "multi-factor authentication"
+	case InterstitialConsent:
+		return "consent"
+	case InterstitialTermsOfService:
+		return "terms of service"
+	case InterstitialCaptcha:
+		return "captcha"
+	default:
+		return "unknown"
+	}
+}
+
+// InterstitialError is returned when the login flow stops at a page that needs user interaction
+// and no ChallengeHandler is set or the handler declined it.
+type InterstitialError struct {
+	Kind    InterstitialKind
+	URL     string
+	Message string
+}
+
+func (e *InterstitialError) Error() string {
+	var action string
+	switch e.Kind {
+	case InterstitialMFA:
+		action = "a one-time password is required, set a ChallengeHandler to provide it"
+	case InterstitialConsent:
+		action = "the app permissions must be accepted, set a ChallengeHandler or log in once with the Comfort Cloud app"
+	case InterstitialTermsOfService:
+		action = "updated terms of service must be accepted, set a ChallengeHandler or log in once with the Comfort Cloud app"
+	case InterstitialCaptcha:
+		action = "a captcha must be solved, log in once with the Comfort Cloud app or a browser and retry later"
+	default:
+		action = "an unexpected page was shown"
+	}
+	message := fmt.Sprintf("login interrupted by %s page: %s", e.Kind, action)
+	if e.Message != "" {
+		message += " (" + e.Message + ")"
+	}
+	return message
+}
+
+// Challenge describes an interstitial page passed to a ChallengeHandler.
+type Challenge struct {
+	Kind    InterstitialKind
+	URL     string
+	Message string
+}
+
+// ChallengeHandler is called when the login flow requires user interaction. For InterstitialMFA it returns
+// the one-time password, for InterstitialConsent and InterstitialTermsOfService any non-empty answer accepts
+// the page. An empty answer declines and aborts the login with an InterstitialError.
+type ChallengeHandler func(challenge Challenge) (string, error)
+
+type auth0ErrorBody struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// checkLoginFormResponse reports a failed username/password submission, detecting captcha requests.
+func checkLoginFormResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var errorBody auth0ErrorBody
+	_ = json.Unmarshal(body, &errorBody)
+	if strings.Contains(strings.ToLower(errorBody.Code+string(body)), "captcha") {
+		return &InterstitialError{Kind: InterstitialCaptcha, URL: resp.Request.URL.String(), Message: errorBody.Description}
+	}
+	if errorBody.Description != "" {
+		return fmt.Errorf("login: expected status 200, got %d: %s", resp.StatusCode, errorBody.Description)
+	}
+	return fmt.Errorf("login: expected status 200, got %d", resp.StatusCode)
+}
+
+func classifyInterstitialURL(location string) InterstitialKind {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return InterstitialUnknown
+	}
+	path := strings.ToLower(parsed.Path)
+	switch {
+	case strings.Contains(path, "mfa"):
+		return InterstitialMFA
+	case strings.Contains(path, "consent"):
+		return InterstitialConsent
+	case strings.Contains(path, "terms") || strings.Contains(path, "tos"):
+		return InterstitialTermsOfService
+	case strings.Contains(path, "captcha"):
+		return InterstitialCaptcha
+	default:
+		return InterstitialUnknown
+	}
+}
+
+// classifyInterstitialPage classifies a page by its URL, the action of its form and the names of the inputs
+// of the form. The text of the page is not used, as it may mention e.g. terms on any page.
+func classifyInterstitialPage(pageURL *url.URL, doc *goquery.Document) InterstitialKind {
+	if kind := classifyInterstitialURL(pageURL.String()); kind != InterstitialUnknown {
+		return kind
+	}
+	form := doc.Find("form").First()
+	if action, ok := form.Attr("action"); ok {
+		if target, err := pageURL.Parse(action); err == nil {
+			if kind := classifyInterstitialURL(target.String()); kind != InterstitialUnknown {
+				return kind
+			}
+		}
+	}
+	kind := InterstitialUnknown
+	form.Find("input").EachWithBreak(func(_ int, input *goquery.Selection) bool {
+		name, _ := input.Attr("name")
+		switch strings.ToLower(name) {
+		case "code", "otp", "otp-code":
+			kind = InterstitialMFA
+		case "captcha":
+			kind = InterstitialCaptcha
+		}
+		return kind == InterstitialUnknown
+	})
+	return kind
+}
+
+// followLoginRedirects follows the login flow from resp until it redirects to the app's RedirectUri and returns
+// that location. Interstitial pages are passed to the ChallengeHandler or reported as InterstitialError.
+func (a *Authentication) followLoginRedirects(resp *http.Response, client *http.Client) (string, error) {
+	for i := 0; i < maxLoginRedirects; i++ {
+		pageURL := resp.Request.URL
+		switch resp.StatusCode {
+		case http.StatusFound, http.StatusSeeOther, http.StatusMovedPermanently, http.StatusTemporaryRedirect:
+			resp.Body.Close()
+			location := resp.Header.Get("Location")
+			if strings.HasPrefix(location, RedirectUri) {
+				return location, nil
+			}
+			target, err := pageURL.Parse(location)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse redirect URL: %w", err)
+			}
+			resp, err = getLoginPage(target.String(), client)
+			if err != nil {
+				return "", err
+			}
+		case http.StatusOK:
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to read login page: %w", err)
+			}
+			resp, err = a.answerInterstitial(pageURL, string(body), client)
+			if err != nil {
+				return "", err
+			}
+		default:
+			resp.Body.Close()
+			return "", fmt.Errorf("login flow: unexpected status code %d from %s", resp.StatusCode, pageURL.Path)
+		}
+	}
+	return "", fmt.Errorf("login flow: too many redirects")
+}
+
+func getLoginPage(pageURL string, client *http.Client) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building login page request: %w", err)
+	}
+	req.Header.Set("User-Agent", "okhttp/4.10.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting login page: %w", err)
+	}
+	return resp, nil
+}
+
+// answerInterstitial asks the ChallengeHandler to answer the page and submits its form.
+func (a *Authentication) answerInterstitial(pageURL *url.URL, body string, client *http.Client) (*http.Response, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	kind := classifyInterstitialPage(pageURL, doc)
+	message := strings.TrimSpace(doc.Find("title").First().Text())
+	interstitial := &InterstitialError{Kind: kind, URL: pageURL.String(), Message: message}
+
+	if kind == InterstitialUnknown || kind == InterstitialCaptcha || a.challengeHandler == nil {
+		return nil, interstitial
+	}
+	answer, err := a.challengeHandler(Challenge{Kind: kind, URL: pageURL.String(), Message: message})
+	if err != nil {
+		return nil, fmt.Errorf("%s challenge failed: %w", kind, err)
+	}
+	if answer == "" {
+		return nil, interstitial
+	}
+
+	form := doc.Find("form").First()
+	action, _ := form.Attr("action")
+	target, err := pageURL.Parse(action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse form action: %w", err)
+	}
+	formData := url.Values{}
+	form.Find("input[type='hidden']").Each(func(_ int, s *goquery.Selection) {
+		name, existsName := s.Attr("name")
+		value, _ := s.Attr("value")
+		if existsName {
+			formData.Set(name, value)
+		}
+	})
+	if kind == InterstitialMFA {
+		formData.Set("code", answer)
+	}
+	if formData.Get("action") == "" {
+		if kind == InterstitialMFA {
+			formData.Set("action", "default")
+		} else {
+			formData.Set("action", "accept")
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.String(), strings.NewReader(formData.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating POST request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "okhttp/4.10.0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error submitting %s page: %w", kind, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,213 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestClassifyInterstitialPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		pageURL string
+		body    string
+		want    InterstitialKind
+	}{
+		{
+			name:    "mfa url",
+			pageURL: "https://authglb.digital.panasonic.com/u/mfa-otp-challenge?state=abc",
+			body:    `<form method="post"><input type="hidden" name="state" value="abc"></form>`,
+			want:    InterstitialMFA,
+		},
+		{
+			name:    "consent form action",
+			pageURL: "https://authglb.digital.panasonic.com/u/page?state=abc",
+			body:    `<form method="post" action="/u/consent?state=abc"><button name="action" value="accept"></button></form>`,
+			want:    InterstitialConsent,
+		},
+		{
+			name:    "terms form action",
+			pageURL: "https://authglb.digital.panasonic.com/u/page",
+			body:    `<form method="post" action="/u/terms-of-use"></form>`,
+			want:    InterstitialTermsOfService,
+		},
+		{
+			name:    "code input",
+			pageURL: "https://authglb.digital.panasonic.com/u/page",
+			body:    `<form method="post"><input type="text" name="code"><input type="hidden" name="state"></form>`,
+			want:    InterstitialMFA,
+		},
+		{
+			name:    "text mentions terms and otp",
+			pageURL: "https://authglb.digital.panasonic.com/u/page",
+			body: `<p>By continuing you accept our terms. No OTP or consent needed.</p>` +
+				`<form method="post"><input type="text" name="username"></form>`,
+			want: InterstitialUnknown,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pageURL, err := url.Parse(test.pageURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := classifyInterstitialPage(pageURL, doc); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// loginServer is a fake Panasonic ID and Comfort Cloud API for the password login that asks for a one-time
+// password after the login callback.
+type loginServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	otpCodes []string
+}
+
+const testOTP = "123456"
+
+func newLoginServer(t *testing.T) *loginServer {
+	s := &loginServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /authorize":
+			http.Redirect(w, r, "/u/login?state=login-state", http.StatusFound)
+		case "GET /u/login":
+			http.SetCookie(w, &http.Cookie{Name: "_csrf", Value: "csrf"})
+		case "POST /usernamepassword/login":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["username"] != "user" || body["password"] != "secret" || body["_csrf"] != "csrf" ||
+				body["state"] != "login-state" {
+				t.Errorf("unexpected login form %v", body)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`<form method="post" action="/login/callback">` +
+				`<input type="hidden" name="wresult" value="result"></form>`))
+		case "POST /login/callback":
+			http.Redirect(w, r, "/u/mfa-otp-challenge?state=login-state", http.StatusFound)
+		case "GET /u/mfa-otp-challenge":
+			_, _ = w.Write([]byte(`<html><head><title>Verify your identity</title></head><body>` +
+				`<form method="post"><input type="hidden" name="state" value="login-state">` +
+				`<input type="text" name="code"></form></body></html>`))
+		case "POST /u/mfa-otp-challenge":
+			_ = r.ParseForm()
+			s.otpCodes = append(s.otpCodes, r.PostForm.Get("code"))
+			if r.PostForm.Get("code") != testOTP || r.PostForm.Get("state") != "login-state" {
+				http.Redirect(w, r, "/u/mfa-otp-challenge?state=login-state", http.StatusFound)
+				return
+			}
+			http.Redirect(w, r, RedirectUri+"?code=auth-code&state=login-state", http.StatusFound)
+		case "POST /oauth/token":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["code"] != "auth-code" {
+				t.Errorf("unexpected token request %v", body)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  testJWT(testNow, testNow.Add(time.Hour)),
+				"refresh_token": "refresh",
+				"id_token":      "id",
+				"scope":         "openid offline_access",
+			})
+		case "POST /auth/v2/login":
+			_, _ = w.Write([]byte(`{"clientId":"acc-client"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestPasswordLoginWithOTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		wantErr bool
+	}{
+		{name: "one-time password", answers: []string{testOTP}},
+		{name: "wrong one-time password is asked again", answers: []string{"000000", testOTP}},
+		{name: "declined", answers: []string{""}, wantErr: true},
+		{name: "no handler", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newLoginServer(t)
+			options := []AuthOption{WithAuthBasePath(server.URL), WithAccBasePath(server.URL), WithAuthClock(testClock())}
+			var challenges []Challenge
+			if test.answers != nil {
+				options = append(options, WithChallengeHandler(func(challenge Challenge) (string, error) {
+					if len(challenges) == len(test.answers) {
+						return "", fmt.Errorf("unexpected challenge %+v", challenge)
+					}
+					challenges = append(challenges, challenge)
+					return test.answers[len(challenges)-1], nil
+				}))
+			}
+			auth := NewAuthentication("user", "secret", nil, options...)
+
+			err := auth.GetNewToken()
+			if test.wantErr {
+				var interstitial *InterstitialError
+				if !errors.As(err, &interstitial) || interstitial.Kind != InterstitialMFA ||
+					interstitial.Message != "Verify your identity" {
+					t.Fatalf("got %v, want an MFA InterstitialError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token := auth.currentToken(); token.AccClientID != "acc-client" || token.RefreshToken != "refresh" {
+				t.Errorf("unexpected token %+v", token)
+			}
+			if len(challenges) != len(test.answers) {
+				t.Fatalf("got %d challenges, want %d", len(challenges), len(test.answers))
+			}
+			for _, challenge := range challenges {
+				if challenge.Kind != InterstitialMFA || !strings.HasPrefix(challenge.URL, server.URL+"/u/mfa-otp-challenge") {
+					t.Errorf("unexpected challenge %+v", challenge)
+				}
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if len(server.otpCodes) != len(test.answers) || server.otpCodes[len(server.otpCodes)-1] != testOTP {
+				t.Errorf("submitted one-time passwords %v, want %v", server.otpCodes, test.answers)
+			}
+		})
+	}
+}
+
+func TestBeginBrowserLoginUsesAuthBasePath(t *testing.T) {
+	auth := NewAuthentication("", "", nil, WithAuthBasePath("http://127.0.0.1:8080"))
+	login := auth.BeginBrowserLogin()
+	authorize, err := url.Parse(login.AuthorizeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorize.Host != "127.0.0.1:8080" || authorize.Path != "/authorize" || authorize.Query().Get("state") != login.State {
+		t.Errorf("got authorize URL %s", login.AuthorizeURL)
+	}
+	if !strings.HasPrefix(BeginBrowserLogin().AuthorizeURL, BasePathAuth+"/authorize?") {
+		t.Errorf("BeginBrowserLogin does not use %s", BasePathAuth)
+	}
+}
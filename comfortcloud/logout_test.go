@@ -0,0 +1,143 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// logoutServer is a fake ACC and Panasonic ID server that records logout and revocation requests.
+type logoutServer struct {
+	*httptest.Server
+	logouts []string
+	revoked []string
+}
+
+func newLogoutServer(t *testing.T) *logoutServer {
+	s := &logoutServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/auth/v2/logout":
+			s.logouts = append(s.logouts, r.Header.Get("x-user-authorization-v2"))
+			_, _ = w.Write([]byte(`{"result":0}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/oauth/revoke":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid revoke request: %v", err)
+			}
+			if body["client_id"] != AppClientId {
+				t.Errorf("client_id = %s", body["client_id"])
+			}
+			s.revoked = append(s.revoked, body["token"])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func writeTokenFile(t *testing.T, token any) string {
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestLogoutRevokesStoredToken(t *testing.T) {
+	validAccessToken := testJWT(testNow, testNow.Add(time.Hour))
+	tests := []struct {
+		name    string
+		token   any
+		logouts []string
+		revoked string
+	}{
+		{
+			name: "valid access token",
+			token: Token{AccessToken: validAccessToken, AccessTokenIssuedAt: testNow.Unix(),
+				AccessTokenExpiresAt: testNow.Add(time.Hour).Unix(), RefreshToken: "refresh-stored", AccClientID: "acc-client"},
+			logouts: []string{"Bearer " + validAccessToken},
+			revoked: "refresh-stored",
+		},
+		{name: "expired access token", token: expiredToken(), revoked: "refresh-old"},
+		{
+			name:    "refresh token only",
+			token:   refreshTokenFile{RefreshToken: "refresh-stored", AccClientID: "acc-client"},
+			revoked: "refresh-stored",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newLogoutServer(t)
+			fileName := writeTokenFile(t, test.token)
+			client := NewClient("user", "password", fileName, WithClock(testClock()),
+				WithAuthOptions(WithAuthBasePath(server.URL), WithAccBasePath(server.URL)))
+
+			if err := client.Logout(); err != nil {
+				t.Fatal(err)
+			}
+			if len(server.logouts) != len(test.logouts) || len(test.logouts) > 0 && server.logouts[0] != test.logouts[0] {
+				t.Errorf("logouts = %v, want %v", server.logouts, test.logouts)
+			}
+			if len(server.revoked) != 1 || server.revoked[0] != test.revoked {
+				t.Errorf("revoked = %v, want [%s]", server.revoked, test.revoked)
+			}
+			if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("token file not removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLogoutWithoutToken(t *testing.T) {
+	server := newLogoutServer(t)
+	client := NewClient("user", "password", filepath.Join(t.TempDir(), "token.json"),
+		WithAuthOptions(WithAuthBasePath(server.URL), WithAccBasePath(server.URL)))
+
+	if err := client.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.logouts) != 0 || len(server.revoked) != 0 {
+		t.Errorf("logouts = %v, revoked = %v, want none", server.logouts, server.revoked)
+	}
+}
+
+func TestLogoutClearsCredentials(t *testing.T) {
+	server := newLogoutServer(t)
+	client := NewClient("user", "password", writeTokenFile(t, expiredToken()), WithClock(testClock()),
+		WithAuthOptions(WithAuthBasePath(server.URL), WithAccBasePath(server.URL)))
+
+	if err := client.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Login(); !errors.Is(err, ErrLoginRequired) {
+		t.Errorf("Login after Logout = %v, want ErrLoginRequired", err)
+	}
+}
+
+func TestLogoutReportsRevokeFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	fileName := writeTokenFile(t, expiredToken())
+	client := NewClient("", "", fileName, WithClock(testClock()),
+		WithAuthOptions(WithAuthBasePath(server.URL), WithAccBasePath(server.URL)))
+
+	if err := client.Logout(); err == nil {
+		t.Error("Logout succeeded although the refresh token was not revoked")
+	}
+	if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("token file not removed: %v", err)
+	}
+}
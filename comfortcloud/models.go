@@ -1,5 +1,10 @@
 package comfortcloud
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Response struct {
 	UIFlg      bool    `json:"uiFlg"`
 	GroupCount int     `json:"groupCount"`
@@ -13,21 +18,36 @@ type Group struct {
 	DeviceList  []Device `json:"deviceList"`
 }
 
+// Device is an air conditioner with its status. TemperatureUnit is the unit chosen in the app and does not
+// change how the parameters are decoded: their temperatures are always in Celsius. Use
+// Parameters.In(device.TemperatureUnit) to show them in the chosen unit.
 type Device struct {
-	DeviceGuid         string     `json:"deviceGuid"`
-	DeviceType         string     `json:"deviceType"`
-	DeviceName         string     `json:"deviceName"`
-	Permission         int        `json:"permission"`
-	TemperatureUnit    int        `json:"temperatureUnit"`
-	SummerHouse        int        `json:"summerHouse"`
-	NanoeStandAlone    bool       `json:"nanoeStandAlone"`
-	AutoMode           bool       `json:"autoMode"`
-	ModeAvlList        ModeAvl    `json:"modeAvlList"`
-	Parameters         Parameters `json:"parameters"`
-	DeviceModuleNumber string     `json:"deviceModuleNumber"`
-	DeviceHashGuid     string     `json:"deviceHashGuid"`
-	ModelVersion       int        `json:"modelVersion"`
-	CoordinableFlg     bool       `json:"coordinableFlg"`
+	DeviceGuid         string          `json:"deviceGuid"`
+	DeviceType         string          `json:"deviceType"`
+	DeviceName         string          `json:"deviceName"`
+	Permission         int             `json:"permission"`
+	TemperatureUnit    TemperatureUnit `json:"temperatureUnit"`
+	SummerHouse        int             `json:"summerHouse"`
+	NanoeStandAlone    bool            `json:"nanoeStandAlone"`
+	AutoMode           bool            `json:"autoMode"`
+	ModeAvlList        ModeAvl         `json:"modeAvlList"`
+	Parameters         Parameters      `json:"parameters"`
+	DeviceModuleNumber string          `json:"deviceModuleNumber"`
+	DeviceHashGuid     string          `json:"deviceHashGuid"`
+	ModelVersion       int             `json:"modelVersion"`
+	CoordinableFlg     bool            `json:"coordinableFlg"`
+	// Timestamp is the time in Unix milliseconds at which the cloud last received the status from the unit.
+	Timestamp int64 `json:"timestamp,omitempty"`
+
+	// Online and LastSeen are derived from Timestamp and are only set on statuses fetched by GetDevice.
+	// Without Timestamp the connectivity is unknown: LastSeen is zero and Online false.
+	Online   bool      `json:"-"`
+	LastSeen time.Time `json:"-"`
+
+	// Extra holds the fields of the API response that are not mapped to a field above.
+	Extra map[string]json.RawMessage `json:"-"`
+	// Raw is the last deviceStatus response the device was decoded from.
+	Raw json.RawMessage `json:"-"`
 }
 
 type ModeAvl struct {
@@ -37,7 +57,7 @@ type ModeAvl struct {
 type Parameters struct {
 	Operate           Power            `json:"operate"`
 	OperationMode     OperationMode    `json:"operationMode"`
-	TemperatureSet    float64          `json:"temperatureSet"`
+	TemperatureSet    Temperature      `json:"temperatureSet"`
 	FanSpeed          FanSpeed         `json:"fanSpeed"`
 	FanAutoMode       AirSwingAutoMode `json:"fanAutoMode"`
 	AirSwingLR        AirSwingLR       `json:"airSwingLR"`
@@ -51,15 +71,27 @@ type Parameters struct {
 	LastSettingMode   int              `json:"lastSettingMode"`
 	InsideCleaning    int              `json:"insideCleaning"`
 	Fireplace         int              `json:"fireplace"`
-	InsideTemperature float64          `json:"insideTemperature"`
-	OutTemperature    float64          `json:"outTemperature"`
+	InsideTemperature Temperature      `json:"insideTemperature"`
+	OutTemperature    Temperature      `json:"outTemperature"`
 	AirQuality        int              `json:"airQuality"`
+
+	// Extra holds the parameters returned by the API that are not mapped to a field above.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// In returns the parameters with all temperatures converted to the given unit,
+// e.g. p.In(device.TemperatureUnit).
+func (p Parameters) In(unit TemperatureUnit) Parameters {
+	p.TemperatureSet = p.TemperatureSet.In(unit)
+	p.InsideTemperature = p.InsideTemperature.In(unit)
+	p.OutTemperature = p.OutTemperature.In(unit)
+	return p
 }
 
 type ParameterOptions struct {
 	Operate           *Power            `json:"operate,omitempty"`
 	OperationMode     *OperationMode    `json:"operationMode,omitempty"`
-	TemperatureSet    *float64          `json:"temperatureSet,omitempty"`
+	TemperatureSet    *Temperature      `json:"temperatureSet,omitempty"`
 	FanSpeed          *FanSpeed         `json:"fanSpeed,omitempty"`
 	FanAutoMode       *AirSwingAutoMode `json:"fanAutoMode,omitempty"`
 	AirSwingLR        *AirSwingLR       `json:"airSwingLR,omitempty"`
@@ -73,8 +105,8 @@ type ParameterOptions struct {
 	LastSettingMode   *int              `json:"lastSettingMode,omitempty"`
 	InsideCleaning    *int              `json:"insideCleaning,omitempty"`
 	Fireplace         *int              `json:"fireplace,omitempty"`
-	InsideTemperature *float64          `json:"insideTemperature,omitempty"`
-	OutTemperature    *float64          `json:"outTemperature,omitempty"`
+	InsideTemperature *Temperature      `json:"insideTemperature,omitempty"`
+	OutTemperature    *Temperature      `json:"outTemperature,omitempty"`
 	AirQuality        *int              `json:"airQuality,omitempty"`
 }
 
@@ -0,0 +1,262 @@
+package comfortcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AccountSeparator separates the account name from the device in device references of a MultiClient,
+// e.g. "office/Meeting room".
+const AccountSeparator = "/"
+
+// MultiClient holds the clients of several Panasonic IDs keyed by account name. Each client keeps its
+// own token file, caches and rate limit, and failures of one account do not affect the others.
+type MultiClient struct {
+	mu       sync.RWMutex
+	clients  map[string]*Client
+	accounts []string
+}
+
+// AccountDevice is a device together with the account it belongs to.
+type AccountDevice struct {
+	Account string
+	Device  Device
+}
+
+// Ref returns the device reference "account/DeviceGuid".
+func (d AccountDevice) Ref() string {
+	return d.Account + AccountSeparator + d.Device.DeviceGuid
+}
+
+// AccountErrors maps account names to the error that occurred for that account.
+type AccountErrors map[string]error
+
+func (e AccountErrors) Error() string {
+	accounts := make([]string, 0, len(e))
+	for account := range e {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	messages := make([]string, 0, len(e))
+	for _, account := range accounts {
+		messages = append(messages, fmt.Sprintf("%s: %v", account, e[account]))
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e AccountErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func NewMultiClient() *MultiClient {
+	return &MultiClient{clients: make(map[string]*Client)}
+}
+
+// AddAccount registers a client under an account name, which must not contain AccountSeparator.
+func (m *MultiClient) AddAccount(name string, client *Client) error {
+	if name == "" || strings.Contains(name, AccountSeparator) {
+		return fmt.Errorf("invalid account name %q", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.clients[name]; ok {
+		return fmt.Errorf("account %s already exists", name)
+	}
+	m.clients[name] = client
+	m.accounts = append(m.accounts, name)
+	return nil
+}
+
+func (m *MultiClient) RemoveAccount(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, name)
+	for i, account := range m.accounts {
+		if account == name {
+			m.accounts = append(m.accounts[:i], m.accounts[i+1:]...)
+			break
+		}
+	}
+}
+
+// Accounts returns the account names in the order they were added.
+func (m *MultiClient) Accounts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.accounts...)
+}
+
+func (m *MultiClient) Client(name string) (*Client, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clients[name]
+	return client, ok
+}
+
+// forEachAccount calls fn for every account in parallel and collects the errors per account.
+func (m *MultiClient) forEachAccount(fn func(account string, client *Client) error) error {
+	accounts := m.Accounts()
+	errs := make(AccountErrors)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, account := range accounts {
+		client, ok := m.Client(account)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(account, client); err != nil {
+				mu.Lock()
+				errs[account] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Login logs in to every account. The returned AccountErrors lists the accounts that failed.
+func (m *MultiClient) Login() error {
+	return m.forEachAccount(func(_ string, client *Client) error {
+		return client.Login()
+	})
+}
+
+// GetDevices returns the devices of all accounts. Devices of accounts that failed are missing from the
+// result, the returned AccountErrors lists these accounts.
+func (m *MultiClient) GetDevices() ([]AccountDevice, error) {
+	var mu sync.Mutex
+	devicesByAccount := make(map[string][]Device)
+	err := m.forEachAccount(func(account string, client *Client) error {
+		devices, err := client.GetDevices()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		devicesByAccount[account] = devices
+		mu.Unlock()
+		return nil
+	})
+
+	var result []AccountDevice
+	for _, account := range m.Accounts() {
+		for _, device := range devicesByAccount[account] {
+			result = append(result, AccountDevice{Account: account, Device: device})
+		}
+	}
+	return result, err
+}
+
+// ResolveDevice resolves a reference "account/device", where device is anything Client.ResolveDevice
+// accepts. A reference without an account is searched in all accounts and must match exactly one device.
+// Accounts in which the reference is ambiguous count as several matches. If no account matches, the
+// errors of the accounts that could not be searched are returned as AccountErrors.
+func (m *MultiClient) ResolveDevice(ref string) (string, *Client, *Device, error) {
+	if account, query, ok := strings.Cut(ref, AccountSeparator); ok {
+		if client, ok := m.Client(account); ok {
+			device, err := client.ResolveDevice(query)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("account %s: %w", account, err)
+			}
+			return account, client, device, nil
+		}
+	}
+
+	var matches []AccountDevice
+	var candidates []string
+	errs := make(AccountErrors)
+	for _, account := range m.Accounts() {
+		client, ok := m.Client(account)
+		if !ok {
+			continue
+		}
+		device, err := client.ResolveDevice(ref)
+		var ambiguous *AmbiguousDeviceError
+		if errors.As(err, &ambiguous) {
+			for _, candidate := range ambiguous.Candidates {
+				candidates = append(candidates, account+AccountSeparator+candidate)
+			}
+		}
+		if errors.Is(err, ErrDeviceNotFound) {
+			continue
+		}
+		if err != nil {
+			errs[account] = err
+			continue
+		}
+		matches = append(matches, AccountDevice{Account: account, Device: *device})
+		candidates = append(candidates, account+AccountSeparator+device.DeviceName)
+	}
+	switch {
+	case len(candidates) > 1:
+		return "", nil, nil, &AmbiguousDeviceError{Query: ref, Candidates: candidates}
+	case len(matches) == 1:
+		client, _ := m.Client(matches[0].Account)
+		return matches[0].Account, client, &matches[0].Device, nil
+	case len(errs) > 0:
+		return "", nil, nil, errs
+	default:
+		return "", nil, nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, ref)
+	}
+}
+
+func (m *MultiClient) GetDevice(ref string) (*Device, error) {
+	account, client, device, err := m.ResolveDevice(ref)
+	if err != nil {
+		return nil, err
+	}
+	device, err = client.GetDevice(device.DeviceGuid)
+	if err != nil {
+		return nil, fmt.Errorf("account %s: %w", account, err)
+	}
+	return device, nil
+}
+
+func (m *MultiClient) SetDevice(ref string, options ...DeviceOption) error {
+	return m.SetDeviceContext(context.Background(), ref, options...)
+}
+
+func (m *MultiClient) SetDeviceContext(ctx context.Context, ref string, options ...DeviceOption) error {
+	account, client, device, err := m.ResolveDevice(ref)
+	if err != nil {
+		return err
+	}
+	if err := client.SetDeviceContext(ctx, device.DeviceGuid, options...); err != nil {
+		return fmt.Errorf("account %s: %w", account, err)
+	}
+	return nil
+}
+
+// GetAllStatuses returns the status of the devices of all accounts keyed by "account/DeviceGuid".
+// Accounts whose device list could not be fetched are reported in the returned AccountErrors.
+func (m *MultiClient) GetAllStatuses(ctx context.Context) (map[string]DeviceStatus, error) {
+	var mu sync.Mutex
+	result := make(map[string]DeviceStatus)
+	err := m.forEachAccount(func(account string, client *Client) error {
+		statuses, err := client.GetAllStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		for guid, status := range statuses {
+			result[account+AccountSeparator+guid] = status
+		}
+		mu.Unlock()
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,43 @@
+package comfortcloud
+
+import (
+	"errors"
+	"testing"
+)
+
+// newListedClient returns a client whose device list is cached, so that resolving devices needs no API.
+func newListedClient(devices ...Device) *Client {
+	client := NewClient("", "", "", WithClock(testClock()))
+	client.registry.set([]Group{{GroupName: "Home", DeviceList: devices}}, testNow)
+	return client
+}
+
+func TestMultiClientResolveDevice(t *testing.T) {
+	multi := NewMultiClient()
+	_ = multi.AddAccount("home", newListedClient(
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom 1"},
+		Device{DeviceGuid: "guid-3", DeviceName: "Bedroom 2"},
+	))
+	_ = multi.AddAccount("office", newListedClient(Device{DeviceGuid: "guid-4", DeviceName: "Meeting room"}))
+	// The device list of this account cannot be fetched without credentials
+	_ = multi.AddAccount("broken", NewClient("", "", "", WithClock(testClock())))
+
+	account, _, device, err := multi.ResolveDevice("meeting")
+	if err != nil || account != "office" || device.DeviceGuid != "guid-4" {
+		t.Errorf("meeting: %s %v %v", account, device, err)
+	}
+
+	_, _, _, err = multi.ResolveDevice("bedroom")
+	var ambiguous *AmbiguousDeviceError
+	if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("bedroom: got %v, want ambiguous device error", err)
+	}
+
+	// Not finding a device refreshes the device lists, which fails without credentials
+	_, _, _, err = multi.ResolveDevice("kitchen")
+	var accountErrors AccountErrors
+	if !errors.As(err, &accountErrors) || len(accountErrors) != 3 || !errors.Is(err, ErrLoginRequired) {
+		t.Errorf("kitchen: got %v, want the errors of all accounts", err)
+	}
+}
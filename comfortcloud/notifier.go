@@ -0,0 +1,309 @@
+package comfortcloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultNotificationCooldown = 15 * time.Minute
+	DefaultWebhookRetries       = 3
+	DefaultWebhookRetryDelay    = 2 * time.Second
+
+	// SignatureHeader carries "sha256=" followed by the hex encoded HMAC-SHA256 of the body with the
+	// webhook secret.
+	SignatureHeader = "X-Comfortcloud-Signature"
+)
+
+// DeviceState is the result of one poll of a device.
+type DeviceState struct {
+	Time   time.Time
+	Device *Device
+	Err    error
+	// LastSeen is the time the device was last seen online, either by the cloud or by a poll.
+	LastSeen time.Time
+}
+
+// NotificationRule decides whether a change between two polls of a device is worth a notification.
+// previous is nil on the first poll of a device.
+type NotificationRule interface {
+	Name() string
+	Check(previous *DeviceState, current DeviceState) (message string, fire bool)
+}
+
+// PowerOnRule fires when a device is switched on.
+type PowerOnRule struct{}
+
+func (PowerOnRule) Name() string { return "power-on" }
+
+func (PowerOnRule) Check(previous *DeviceState, current DeviceState) (string, bool) {
+	if previous == nil || previous.Device == nil || current.Device == nil {
+		return "", false
+	}
+	if previous.Device.Parameters.Operate == PowerOff && current.Device.Parameters.Operate == PowerOn {
+		return fmt.Sprintf("%s was switched on", current.Device.DeviceName), true
+	}
+	return "", false
+}
+
+// TemperatureAboveRule fires when the inside temperature rises above Threshold.
+type TemperatureAboveRule struct {
+	Threshold Temperature
+}
+
+func (r TemperatureAboveRule) Name() string { return "temperature-above" }
+
+func (r TemperatureAboveRule) Check(previous *DeviceState, current DeviceState) (string, bool) {
+	if current.Device == nil || !r.above(current.Device) {
+		return "", false
+	}
+	if previous != nil && previous.Device != nil && r.above(previous.Device) {
+		return "", false
+	}
+	return fmt.Sprintf("%s inside temperature %s is above %s", current.Device.DeviceName,
+		current.Device.Parameters.InsideTemperature, r.Threshold), true
+}
+
+func (r TemperatureAboveRule) above(device *Device) bool {
+	inside := device.Parameters.InsideTemperature
+	return inside.Available() && inside.Celsius() > r.Threshold.Celsius()
+}
+
+// OfflineRule fires when a device has not been seen by the cloud or could not be read for at least After,
+// or as soon as the API reports it as offline.
+type OfflineRule struct {
+	After time.Duration
+}
+
+func (r OfflineRule) Name() string { return "offline" }
+
+func (r OfflineRule) Check(previous *DeviceState, current DeviceState) (string, bool) {
+	if !r.offline(current) || (previous != nil && r.offline(*previous)) {
+		return "", false
+	}
+	message := fmt.Sprintf("device is offline since %s", current.LastSeen.Format(time.RFC3339))
+	if current.Err != nil {
+		message += fmt.Sprintf(": %v", current.Err)
+	}
+	return message, true
+}
+
+func (r OfflineRule) offline(state DeviceState) bool {
+	if errors.Is(state.Err, ErrDeviceOffline) {
+		return true
+	}
+	unreachable := state.Err != nil || state.Device != nil && state.Device.offline()
+	return unreachable && !state.LastSeen.IsZero() && state.Time.Sub(state.LastSeen) >= r.After
+}
+
+// Webhook is a URL notifications are POSTed to. If Secret is set, the body is signed in SignatureHeader.
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+// Notification is the JSON payload POSTed to webhooks.
+type Notification struct {
+	Rule       string      `json:"rule"`
+	DeviceGuid string      `json:"deviceGuid"`
+	DeviceName string      `json:"deviceName,omitempty"`
+	Time       time.Time   `json:"time"`
+	Message    string      `json:"message"`
+	Parameters *Parameters `json:"parameters,omitempty"`
+}
+
+// Notifier polls devices, evaluates its rules on every change and POSTs the resulting notifications to
+// its webhooks. A rule fires at most once per device within Cooldown. Poll and Run may be called
+// concurrently; the polls are serialized. The exported fields must not be changed while polling.
+type Notifier struct {
+	Controller DeviceController
+	// Devices are the device IDs to poll.
+	Devices  []string
+	Rules    []NotificationRule
+	Webhooks []Webhook
+	// Interval is the time between two polls of Run.
+	Interval   time.Duration
+	Cooldown   time.Duration
+	Retries    int
+	RetryDelay time.Duration
+	HTTPClient *http.Client
+	Clock      Clock
+
+	mu           sync.Mutex
+	states       map[string]DeviceState
+	lastNotified map[string]time.Time
+}
+
+func NewNotifier(controller DeviceController, devices []string, rules []NotificationRule, webhooks []Webhook) *Notifier {
+	return &Notifier{
+		Controller: controller,
+		Devices:    devices,
+		Rules:      rules,
+		Webhooks:   webhooks,
+		Interval:   time.Minute,
+		Cooldown:   DefaultNotificationCooldown,
+		Retries:    DefaultWebhookRetries,
+		RetryDelay: DefaultWebhookRetryDelay,
+		HTTPClient: http.DefaultClient,
+		Clock:      systemClock{},
+	}
+}
+
+// Poll reads every device once and sends the notifications of the rules that fire. The cooldown of a rule
+// only starts when its notification was delivered to all webhooks.
+func (n *Notifier) Poll(ctx context.Context) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.states == nil {
+		n.states = make(map[string]DeviceState)
+		n.lastNotified = make(map[string]time.Time)
+	}
+	var errs []error
+	for _, deviceID := range n.Devices {
+		now := n.now()
+		device, err := n.Controller.GetDevice(deviceID)
+		current := DeviceState{Time: now, Device: device, Err: err}
+
+		var previous *DeviceState
+		if state, ok := n.states[deviceID]; ok {
+			previous = &state
+			current.LastSeen = state.LastSeen
+		}
+		if device != nil && device.Online {
+			current.LastSeen = now
+		} else if device != nil && !device.LastSeen.IsZero() {
+			current.LastSeen = device.LastSeen
+		} else if current.LastSeen.IsZero() {
+			// A device that was never reachable counts as unreachable since the first poll.
+			current.LastSeen = now
+		}
+		n.states[deviceID] = current
+
+		for _, rule := range n.Rules {
+			message, fire := rule.Check(previous, current)
+			if !fire {
+				continue
+			}
+			key := rule.Name() + "\x00" + deviceID
+			if last, ok := n.lastNotified[key]; ok && now.Sub(last) < n.Cooldown {
+				slog.Debug("Notification suppressed by cooldown", "rule", rule.Name(), "device", deviceID)
+				continue
+			}
+			notification := Notification{Rule: rule.Name(), DeviceGuid: deviceID, Time: now, Message: message}
+			if device != nil {
+				notification.DeviceGuid = device.DeviceGuid
+				notification.DeviceName = device.DeviceName
+				notification.Parameters = &device.Parameters
+			}
+			if err := n.Notify(ctx, notification); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			n.lastNotified[key] = now
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) now() time.Time {
+	if n.Clock == nil {
+		return time.Now()
+	}
+	return n.Clock.Now()
+}
+
+// Run calls Poll every Interval until ctx is done.
+func (n *Notifier) Run(ctx context.Context) error {
+	if n.Interval <= 0 {
+		return errors.New("notifier interval must be positive")
+	}
+	ticker := time.NewTicker(n.Interval)
+	defer ticker.Stop()
+	for {
+		if err := n.Poll(ctx); err != nil {
+			slog.Warn("Sending notifications failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notify sends a notification to all webhooks.
+func (n *Notifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	var errs []error
+	for _, webhook := range n.Webhooks {
+		if err := n.deliver(ctx, webhook, body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver POSTs the body to a webhook and retries network errors, 429 and 5xx responses with exponential backoff.
+func (n *Notifier) deliver(ctx context.Context, webhook Webhook, body []byte) error {
+	delay := n.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, webhook, body)
+		if err == nil || !retry || attempt >= n.Retries {
+			return err
+		}
+		slog.Debug("Webhook delivery failed, retrying", "url", webhook.URL, "attempt", attempt+1, "error", err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, webhook Webhook, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, SignPayload(webhook.Secret, body))
+	}
+	client := n.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("expected status 2xx, got %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// SignPayload returns the value of SignatureHeader for a body. Receivers compare it with hmac.Equal.
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,300 @@
+package comfortcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeController is a DeviceController that returns fixed device states.
+type fakeController struct {
+	mu      sync.Mutex
+	devices map[string]*Device
+	errs    map[string]error
+}
+
+func (c *fakeController) GetDevice(deviceID string) (*Device, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.errs[deviceID]; err != nil {
+		return nil, err
+	}
+	device := *c.devices[deviceID]
+	return &device, nil
+}
+
+func (c *fakeController) SetDevice(deviceID string, options ...DeviceOption) error {
+	return errors.New("not supported")
+}
+
+func (c *fakeController) setOperate(deviceID string, operate Power) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices[deviceID].Parameters.Operate = operate
+}
+
+// webhookServer records webhook deliveries and answers with the given status codes, then with 200.
+type webhookServer struct {
+	*httptest.Server
+	mu         sync.Mutex
+	statuses   []int
+	bodies     [][]byte
+	signatures []string
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read webhook body: %v", err)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.bodies = append(s.bodies, body)
+		s.signatures = append(s.signatures, r.Header.Get(SignatureHeader))
+		if len(s.statuses) > 0 {
+			w.WriteHeader(s.statuses[0])
+			s.statuses = s.statuses[1:]
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) deliveries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func newTestNotifier(controller DeviceController, rules []NotificationRule, webhooks []Webhook, now *time.Time) *Notifier {
+	notifier := NewNotifier(controller, []string{"guid-1"}, rules, webhooks)
+	notifier.RetryDelay = time.Millisecond
+	notifier.Clock = ClockFunc(func() time.Time { return *now })
+	return notifier
+}
+
+func TestNotificationRules(t *testing.T) {
+	device := func(operate Power, inside float64, online bool) *Device {
+		return &Device{DeviceName: "Living room", Online: online,
+			Parameters: Parameters{Operate: operate, InsideTemperature: Celsius(inside)}}
+	}
+	state := func(device *Device, lastSeen time.Time) *DeviceState {
+		device.LastSeen = lastSeen
+		return &DeviceState{Time: testNow, Device: device, LastSeen: lastSeen}
+	}
+	tests := []struct {
+		name     string
+		rule     NotificationRule
+		previous *DeviceState
+		current  *DeviceState
+		fire     bool
+	}{
+		{name: "power on", rule: PowerOnRule{}, previous: state(device(PowerOff, 20, true), testNow),
+			current: state(device(PowerOn, 20, true), testNow), fire: true},
+		{name: "stays on", rule: PowerOnRule{}, previous: state(device(PowerOn, 20, true), testNow),
+			current: state(device(PowerOn, 20, true), testNow)},
+		{name: "power on first poll", rule: PowerOnRule{}, current: state(device(PowerOn, 20, true), testNow)},
+		{name: "rises above", rule: TemperatureAboveRule{Threshold: Celsius(25)},
+			previous: state(device(PowerOn, 24, true), testNow), current: state(device(PowerOn, 26, true), testNow),
+			fire: true},
+		{name: "stays above", rule: TemperatureAboveRule{Threshold: Celsius(25)},
+			previous: state(device(PowerOn, 26, true), testNow), current: state(device(PowerOn, 27, true), testNow)},
+		{name: "above on first poll", rule: TemperatureAboveRule{Threshold: Celsius(25)},
+			current: state(device(PowerOn, 26, true), testNow), fire: true},
+		{name: "offline after", rule: OfflineRule{After: time.Hour},
+			previous: state(device(PowerOn, 20, false), testNow.Add(-50*time.Minute)),
+			current:  state(device(PowerOn, 20, false), testNow.Add(-time.Hour)), fire: true},
+		{name: "offline too short", rule: OfflineRule{After: time.Hour},
+			current: state(device(PowerOn, 20, false), testNow.Add(-30*time.Minute))},
+		{name: "unknown connectivity", rule: OfflineRule{After: time.Hour},
+			current: &DeviceState{Time: testNow, Device: device(PowerOn, 20, false), LastSeen: testNow.Add(-2 * time.Hour)}},
+		{name: "reported offline", rule: OfflineRule{After: time.Hour},
+			current: &DeviceState{Time: testNow, Err: ErrDeviceOffline, LastSeen: testNow}, fire: true},
+		{name: "stays offline", rule: OfflineRule{After: time.Hour},
+			previous: &DeviceState{Time: testNow, Err: ErrDeviceOffline, LastSeen: testNow},
+			current:  &DeviceState{Time: testNow, Err: ErrDeviceOffline, LastSeen: testNow}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, fire := test.rule.Check(test.previous, *test.current)
+			if fire != test.fire {
+				t.Errorf("fire = %t, want %t (message %q)", fire, test.fire, message)
+			}
+			if fire && message == "" {
+				t.Error("rule fired without message")
+			}
+		})
+	}
+}
+
+func TestNotifierPoll(t *testing.T) {
+	controller := &fakeController{devices: map[string]*Device{"guid-1": {DeviceGuid: "guid-1", DeviceName: "Living room",
+		Parameters: Parameters{Operate: PowerOff}}}}
+	server := newWebhookServer(t)
+	now := testNow
+	notifier := newTestNotifier(controller, []NotificationRule{PowerOnRule{}}, []Webhook{{URL: server.URL}}, &now)
+
+	if err := notifier.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	controller.setOperate("guid-1", PowerOn)
+	now = now.Add(time.Minute)
+	if err := notifier.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.deliveries() != 1 {
+		t.Fatalf("got %d deliveries, want 1", server.deliveries())
+	}
+	var notification Notification
+	if err := json.Unmarshal(server.bodies[0], &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Rule != "power-on" || notification.DeviceGuid != "guid-1" || notification.DeviceName != "Living room" ||
+		!notification.Time.Equal(now) || notification.Parameters == nil || notification.Parameters.Operate != PowerOn {
+		t.Errorf("unexpected notification %+v", notification)
+	}
+	if server.signatures[0] != "" {
+		t.Errorf("unsigned webhook got signature %s", server.signatures[0])
+	}
+}
+
+func TestNotifierCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		// elapsed is the time between the first and the second time the device is switched on.
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "within cooldown", elapsed: 10 * time.Minute, want: 1},
+		{name: "after cooldown", elapsed: 20 * time.Minute, want: 2},
+		{name: "failed delivery", statuses: []int{http.StatusBadRequest}, elapsed: 10 * time.Minute, want: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controller := &fakeController{devices: map[string]*Device{"guid-1": {DeviceGuid: "guid-1",
+				Parameters: Parameters{Operate: PowerOff}}}}
+			server := newWebhookServer(t, test.statuses...)
+			now := testNow
+			notifier := newTestNotifier(controller, []NotificationRule{PowerOnRule{}}, []Webhook{{URL: server.URL}}, &now)
+
+			poll := func(operate Power, at time.Time) error {
+				controller.setOperate("guid-1", operate)
+				now = at
+				return notifier.Poll(context.Background())
+			}
+			_ = poll(PowerOff, testNow)
+			firstErr := poll(PowerOn, testNow.Add(time.Minute))
+			_ = poll(PowerOff, testNow.Add(2*time.Minute))
+			if err := poll(PowerOn, testNow.Add(time.Minute+test.elapsed)); err != nil {
+				t.Fatal(err)
+			}
+
+			if (firstErr != nil) != (len(test.statuses) > 0) {
+				t.Errorf("first delivery error = %v", firstErr)
+			}
+			if got := server.deliveries(); got != test.want {
+				t.Errorf("got %d deliveries, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNotifierSignature(t *testing.T) {
+	server := newWebhookServer(t)
+	notifier := NewNotifier(nil, nil, nil, []Webhook{{URL: server.URL, Secret: "secret"}})
+
+	if err := notifier.Notify(context.Background(), Notification{Rule: "test", DeviceGuid: "guid-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if server.deliveries() != 1 {
+		t.Fatalf("got %d deliveries, want 1", server.deliveries())
+	}
+	if got, want := server.signatures[0], SignPayload("secret", server.bodies[0]); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+	// echo -n '{}' | openssl dgst -sha256 -hmac secret
+	if got := SignPayload("secret", []byte("{}")); got != "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13" {
+		t.Errorf("SignPayload = %s", got)
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retries    int
+		deliveries int
+		fail       bool
+	}{
+		{name: "success", deliveries: 1, retries: 3},
+		{name: "server errors", statuses: []int{503, 500}, retries: 3, deliveries: 3},
+		{name: "rate limited", statuses: []int{429}, retries: 3, deliveries: 2},
+		{name: "retries exhausted", statuses: []int{503, 503, 503}, retries: 2, deliveries: 3, fail: true},
+		{name: "client error", statuses: []int{400}, retries: 3, deliveries: 1, fail: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newWebhookServer(t, test.statuses...)
+			notifier := NewNotifier(nil, nil, nil, []Webhook{{URL: server.URL}})
+			notifier.Retries = test.retries
+			notifier.RetryDelay = time.Millisecond
+
+			err := notifier.Notify(context.Background(), Notification{Rule: "test"})
+			if (err != nil) != test.fail {
+				t.Errorf("error = %v, want failure %t", err, test.fail)
+			}
+			if got := server.deliveries(); got != test.deliveries {
+				t.Errorf("got %d deliveries, want %d", got, test.deliveries)
+			}
+		})
+	}
+}
+
+func TestNotifierRetryStopsOnCancel(t *testing.T) {
+	server := newWebhookServer(t, 503, 503)
+	notifier := NewNotifier(nil, nil, nil, []Webhook{{URL: server.URL}})
+	notifier.RetryDelay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := notifier.Notify(ctx, Notification{Rule: "test"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := server.deliveries(); got != 1 {
+		t.Errorf("got %d deliveries, want 1", got)
+	}
+}
+
+func TestNotifierConcurrentPolls(t *testing.T) {
+	controller := &fakeController{devices: map[string]*Device{"guid-1": {DeviceGuid: "guid-1",
+		Parameters: Parameters{Operate: PowerOn}}}}
+	server := newWebhookServer(t)
+	notifier := NewNotifier(controller, []string{"guid-1"}, []NotificationRule{TemperatureAboveRule{}},
+		[]Webhook{{URL: server.URL}})
+	notifier.Clock = testClock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := notifier.Poll(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := server.deliveries(); got != 0 {
+		t.Errorf("got %d deliveries, want 0", got)
+	}
+}
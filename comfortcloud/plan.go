@@ -0,0 +1,145 @@
+package comfortcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Plan describes what SetDevice would change on a device.
+type Plan struct {
+	DeviceGuid string            `json:"deviceGuid"`
+	DeviceName string            `json:"deviceName"`
+	Current    Parameters        `json:"current"`
+	Changes    []ParameterChange `json:"changes"`
+	// Payload is the exact body that would be POSTed to /deviceStatus/control.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ParameterChange is a parameter whose requested value differs from its current value.
+type ParameterChange struct {
+	Parameter string `json:"parameter"`
+	From      any    `json:"from"`
+	To        any    `json:"to"`
+}
+
+// NoOp reports whether the command would not change any parameter.
+func (p *Plan) NoOp() bool {
+	return len(p.Changes) == 0
+}
+
+func (p *Plan) String() string {
+	if p.NoOp() {
+		return p.DeviceName + ": no changes"
+	}
+	changes := make([]string, len(p.Changes))
+	for i, change := range p.Changes {
+		changes[i] = fmt.Sprintf("%s %v → %v", change.Parameter, change.From, change.To)
+	}
+	return p.DeviceName + ": " + strings.Join(changes, ", ")
+}
+
+// WithDryRun makes SetDevice compute and log the plan of every command instead of sending it, and succeed
+// without changing the device. SetDevice is the only control operation of the client, so this covers every
+// command, including those of automations such as a Thermostat. Use WithPlanListener to receive the plans.
+// Commands are recorded in the audit log with the outcome "dry-run".
+func WithDryRun() ClientOption {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+// WithPlanListener registers a function that is called with the plan of every command in dry-run mode.
+func WithPlanListener(listener func(*Plan)) ClientOption {
+	return func(c *Client) {
+		c.planListener = listener
+	}
+}
+
+// PlanSetDevice returns what SetDevice would change on the device without sending anything.
+func (c *Client) PlanSetDevice(deviceID string, options ...DeviceOption) (*Plan, error) {
+	parameter := &ParameterOptions{}
+	for _, option := range options {
+		option(parameter)
+	}
+	device, err := c.ResolveDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return c.planSetDevice(*device, parameter)
+}
+
+func (c *Client) planSetDevice(device Device, parameter *ParameterOptions) (*Plan, error) {
+	current, err := c.GetDevice(device.DeviceGuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current parameters: %w", err)
+	}
+	payload, err := json.Marshal(controlPayload(device, parameter))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return &Plan{
+		DeviceGuid: device.DeviceGuid,
+		DeviceName: device.DeviceName,
+		Current:    current.Parameters,
+		Changes:    parameter.diff(current.Parameters),
+		Payload:    payload,
+	}, nil
+}
+
+// dryRunSetDevice logs, audits and reports the plan of a command instead of sending it.
+func (c *Client) dryRunSetDevice(ctx context.Context, device Device, parameter *ParameterOptions) error {
+	plan, err := c.planSetDevice(device, parameter)
+	if err != nil {
+		c.auditSetDevice(ctx, device, *parameter, nil, AuditOutcomeDryRun, err)
+		return err
+	}
+	slog.Info("Dry run, command not sent", "plan", plan.String(), "payload", string(plan.Payload))
+	c.auditSetDevice(ctx, device, *parameter, &plan.Current, AuditOutcomeDryRun, nil)
+	if c.planListener != nil {
+		c.planListener(plan)
+	}
+	return nil
+}
+
+// diff returns the set options that differ from the given parameters, named like in the JSON payload.
+func (o *ParameterOptions) diff(current Parameters) []ParameterChange {
+	var changes []ParameterChange
+	options := reflect.ValueOf(o).Elem()
+	parameters := reflect.ValueOf(current)
+	for i := 0; i < options.NumField(); i++ {
+		field := options.Type().Field(i)
+		option := options.Field(i)
+		if option.IsNil() {
+			continue
+		}
+		from := parameters.FieldByName(field.Name).Interface()
+		to := option.Elem().Interface()
+		// Compare the encoded values, so that e.g. temperatures in different units are equal
+		fromJSON, fromErr := json.Marshal(from)
+		toJSON, toErr := json.Marshal(to)
+		if fromErr == nil && toErr == nil && bytes.Equal(fromJSON, toJSON) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		changes = append(changes, ParameterChange{Parameter: name, From: from, To: to})
+	}
+	return changes
+}
+
+// PlanSetDevice returns what SetDevice would change on the referenced device without sending anything.
+func (m *MultiClient) PlanSetDevice(ref string, options ...DeviceOption) (*Plan, error) {
+	account, client, device, err := m.ResolveDevice(ref)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := client.PlanSetDevice(device.DeviceGuid, options...)
+	if err != nil {
+		return nil, fmt.Errorf("account %s: %w", account, err)
+	}
+	return plan, nil
+}
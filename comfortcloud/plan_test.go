@@ -0,0 +1,108 @@
+package comfortcloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDryRunReturnsPlan(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Device{DeviceGuid: "guid-1", Parameters: Parameters{
+			Operate: PowerOff, TemperatureSet: Celsius(20),
+		}})
+	}))
+	defer server.Close()
+
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit := NewAuditLog(auditFile)
+	defer audit.Close()
+	var plans []*Plan
+	client := NewClient("", "", "", WithClock(testClock()), WithDryRun(), WithAuditLog(audit),
+		WithPlanListener(func(plan *Plan) { plans = append(plans, plan) }),
+		WithAuthOptions(WithAccBasePath(server.URL)))
+	client.registry.set([]Group{{DeviceList: []Device{{DeviceGuid: "guid-1", DeviceName: "Living room"}}}}, testNow)
+	client.auth.token = &Token{AccessToken: testJWT(testNow, testNow.Add(time.Hour)), RefreshToken: "refresh"}
+
+	err := client.SetDeviceContext(context.Background(), "Living room", WithPower(PowerOn), WithTemperature(Celsius(20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts != 0 {
+		t.Errorf("dry run sent %d commands", posts)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("got %d plans, want 1", len(plans))
+	}
+	plan := plans[0]
+	if len(plan.Changes) != 1 || plan.Changes[0].Parameter != "operate" || plan.Changes[0].To != PowerOn {
+		t.Errorf("unexpected changes %+v", plan.Changes)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(plan.Payload, &payload); err != nil || payload["deviceGuid"] != "guid-1" {
+		t.Errorf("unexpected payload %s: %v", plan.Payload, err)
+	}
+
+	entries, err := QueryAuditLog(auditFile, AuditQuery{Outcome: AuditOutcomeDryRun})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Previous == nil || entries[0].Previous.Operate != PowerOff {
+		t.Errorf("unexpected audit entries %+v", entries)
+	}
+}
+
+func TestDryRunWithDeviceControllers(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Device{DeviceGuid: "guid-1", Parameters: Parameters{
+			Operate: PowerOn, TemperatureSet: Celsius(21),
+		}})
+	}))
+	defer server.Close()
+
+	var plans []*Plan
+	client := NewClient("", "", "", WithClock(testClock()), WithDryRun(),
+		WithPlanListener(func(plan *Plan) { plans = append(plans, plan) }),
+		WithAuthOptions(WithAccBasePath(server.URL)))
+	client.registry.set([]Group{{DeviceList: []Device{{DeviceGuid: "guid-1", DeviceName: "Living room"}}}}, testNow)
+	client.auth.token = &Token{AccessToken: testJWT(testNow, testNow.Add(time.Hour)), RefreshToken: "refresh"}
+
+	thermostat := &Thermostat{
+		Controller: client,
+		DeviceID:   "guid-1",
+		Source:     TemperatureSourceFunc(func(context.Context) (Temperature, error) { return Celsius(19), nil }),
+		Strategy:   &HysteresisStrategy{Band: Celsius(0.5), Step: Celsius(1)},
+		Target:     Celsius(21),
+		Clock:      testClock(),
+	}
+	decision, err := thermostat.Step(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Sent || thermostat.setpoint.Celsius() != 22 {
+		t.Errorf("decision %+v, setpoint %v, want 22 recorded", decision, thermostat.setpoint)
+	}
+
+	multi := NewMultiClient()
+	_ = multi.AddAccount("home", client)
+	if err := multi.SetDevice("home/Living room", WithPower(PowerOff)); err != nil {
+		t.Errorf("multi client dry run failed: %v", err)
+	}
+	if posts != 0 || len(plans) != 2 {
+		t.Errorf("got %d commands and %d plans, want 0 and 2", posts, len(plans))
+	}
+}
@@ -0,0 +1,37 @@
+package comfortcloud
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests at least interval apart.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	sleep    func(time.Duration)
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval, sleep: time.Sleep}
+}
+
+// wait blocks until the next request may be sent, measuring time with clock. A nil rateLimiter never blocks.
+func (l *rateLimiter) wait(clock Clock) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := clock.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay := start.Sub(now); delay > 0 {
+		l.sleep(delay)
+	}
+}
@@ -0,0 +1,62 @@
+package comfortcloud
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sleepRecorder records the delays of a rateLimiter and advances the clock instead of sleeping.
+type sleepRecorder struct {
+	clock  *manualClock
+	delays []time.Duration
+}
+
+func (r *sleepRecorder) sleep(d time.Duration) {
+	r.delays = append(r.delays, d)
+	r.clock.advance(d)
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &manualClock{now: testNow}
+	recorder := &sleepRecorder{clock: clock}
+	limiter := newRateLimiter(time.Second)
+	limiter.sleep = recorder.sleep
+
+	limiter.wait(clock)
+	limiter.wait(clock)
+	limiter.wait(clock)
+	clock.advance(300 * time.Millisecond)
+	limiter.wait(clock)
+	// A request after a pause longer than the interval is not delayed.
+	clock.advance(5 * time.Second)
+	limiter.wait(clock)
+
+	want := []time.Duration{time.Second, time.Second, 700 * time.Millisecond}
+	if !reflect.DeepEqual(recorder.delays, want) {
+		t.Errorf("got delays %v, want %v", recorder.delays, want)
+	}
+
+	var disabled *rateLimiter
+	disabled.wait(clock)
+}
+
+func TestRateLimitUsesClientClock(t *testing.T) {
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	clock := &manualClock{now: testNow}
+	client := newFakeAccClient(t, server, WithRateLimit(time.Minute), WithClock(clock))
+	recorder := &sleepRecorder{clock: clock}
+	client.auth.limiter.sleep = recorder.sleep
+
+	for _, pause := range []time.Duration{0, 0, 2 * time.Minute} {
+		clock.advance(pause)
+		if _, err := client.GetDevice("guid-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first GetDevice lists the devices and fetches the status, the second one is delayed as well,
+	// the third one follows a pause longer than the interval.
+	if want := []time.Duration{time.Minute, time.Minute}; !reflect.DeepEqual(recorder.delays, want) {
+		t.Errorf("got delays %v, want %v", recorder.delays, want)
+	}
+}
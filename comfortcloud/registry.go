@@ -0,0 +1,158 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultDeviceCacheTTL = 10 * time.Minute
+
+// deviceRegistry caches the result of the group listing.
+type deviceRegistry struct {
+	mu        sync.Mutex
+	groups    []Group
+	devices   []Device
+	fetchedAt time.Time
+	ttl       time.Duration
+	fileName  string
+	loaded    bool
+	// changed holds the devices controlled since fetchedAt, whose status in the listing is outdated.
+	changed map[string]bool
+}
+
+type registrySnapshot struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Groups    []Group   `json:"groups"`
+}
+
+func newDeviceRegistry() *deviceRegistry {
+	return &deviceRegistry{ttl: DefaultDeviceCacheTTL}
+}
+
+func (r *deviceRegistry) set(groups []Group, fetchedAt time.Time) {
+	r.groups = groups
+	r.devices = nil
+	for _, group := range groups {
+		r.devices = append(r.devices, group.DeviceList...)
+	}
+	r.fetchedAt = fetchedAt
+	r.changed = nil
+}
+
+// markChanged records that the status of a device in the listing is outdated.
+func (r *deviceRegistry) markChanged(guid string) {
+	if r.changed == nil {
+		r.changed = make(map[string]bool)
+	}
+	r.changed[guid] = true
+}
+
+func (r *deviceRegistry) isFresh(now time.Time) bool {
+	if r.fetchedAt.IsZero() || r.ttl <= 0 {
+		return false
+	}
+	return now.Sub(r.fetchedAt) < r.ttl
+}
+
+// loadFile populates the registry from its cache file once. A missing or unreadable file is not an error.
+func (r *deviceRegistry) loadFile() {
+	if r.loaded || r.fileName == "" {
+		return
+	}
+	r.loaded = true
+	data, err := os.ReadFile(r.fileName)
+	if err != nil {
+		return
+	}
+	var snapshot registrySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		slog.Warn("Ignoring invalid device cache file", "file", r.fileName, "error", err)
+		return
+	}
+	r.set(snapshot.Groups, snapshot.FetchedAt)
+}
+
+func (r *deviceRegistry) saveFile() error {
+	if r.fileName == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(registrySnapshot{FetchedAt: r.fetchedAt, Groups: r.groups}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal device cache: %w", err)
+	}
+	if err := os.WriteFile(r.fileName, data, 0600); err != nil {
+		return fmt.Errorf("failed to write device cache file: %w", err)
+	}
+	return nil
+}
+
+func (r *deviceRegistry) invalidate() error {
+	r.groups = nil
+	r.devices = nil
+	r.fetchedAt = time.Time{}
+	r.changed = nil
+	r.loaded = true
+	if r.fileName == "" {
+		return nil
+	}
+	if err := os.Remove(r.fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove device cache file: %w", err)
+	}
+	return nil
+}
+
+// refreshDevices fetches the group listing and stores it in the registry. The caller holds c.registry.mu.
+func (c *Client) refreshDevices() error {
+	if err := c.ensureLoggedIn(); err != nil {
+		return err
+	}
+
+	response, err := c.auth.ExecuteGet(c.getGroupURL(), "get_groups", http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to fetch groups: %w", err)
+	}
+
+	var result Response
+	if err := json.Unmarshal(response, &result); err != nil {
+		return fmt.Errorf("failed to parse groups response: %w", err)
+	}
+
+	c.registry.set(result.GroupList, c.clock.Now())
+	if err := c.registry.saveFile(); err != nil {
+		slog.Warn("Failed to persist device cache", "error", err)
+	}
+	return nil
+}
+
+// ensureDevices populates the registry from the cache file or the API if it is empty or expired.
+// The caller holds c.registry.mu.
+func (c *Client) ensureDevices() error {
+	c.registry.loadFile()
+	if c.registry.isFresh(c.clock.Now()) {
+		return nil
+	}
+	return c.refreshDevices()
+}
+
+// InvalidateDevices discards the cached group and device list, including the cache file.
+func (c *Client) InvalidateDevices() error {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	return c.registry.invalidate()
+}
+
+// GetGroups returns the groups with their devices, using the cache when it is fresh.
+func (c *Client) GetGroups() ([]Group, error) {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	if err := c.ensureDevices(); err != nil {
+		return nil, err
+	}
+	return append([]Group(nil), c.registry.groups...), nil
+}
@@ -0,0 +1,140 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDeviceRegistryTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		elapsed  []time.Duration
+		requests []int
+	}{
+		{name: "within ttl", ttl: 10 * time.Minute, elapsed: []time.Duration{0, 9 * time.Minute}, requests: []int{1, 1}},
+		{name: "expired", ttl: 10 * time.Minute, elapsed: []time.Duration{0, 10 * time.Minute, time.Minute},
+			requests: []int{1, 2, 2}},
+		{name: "disabled", ttl: 0, elapsed: []time.Duration{0, 0}, requests: []int{1, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+			clock := &manualClock{now: testNow}
+			client := newFakeAccClient(t, server, WithClock(clock), WithDeviceCacheTTL(test.ttl))
+
+			for i, elapsed := range test.elapsed {
+				clock.advance(elapsed)
+				if _, err := client.GetDevices(); err != nil {
+					t.Fatal(err)
+				}
+				if got := server.groupRequestCount(); got != test.requests[i] {
+					t.Errorf("after %d calls: got %d group requests, want %d", i+1, got, test.requests[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceRegistryMarkChanged(t *testing.T) {
+	server := newFakeAccServer(t,
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom"})
+	client := newFakeAccClient(t, server)
+
+	if err := client.SetDevice("guid-1", WithPower(PowerOn)); err != nil {
+		t.Fatal(err)
+	}
+	client.registry.mu.Lock()
+	changed := client.registry.changed
+	client.registry.mu.Unlock()
+	if !changed["guid-1"] || changed["guid-2"] {
+		t.Errorf("changed = %v, want only guid-1", changed)
+	}
+
+	// A new listing contains the current statuses.
+	if err := client.FetchGroupsAndDevices(); err != nil {
+		t.Fatal(err)
+	}
+	client.registry.mu.Lock()
+	defer client.registry.mu.Unlock()
+	if len(client.registry.changed) != 0 {
+		t.Errorf("changed = %v after refresh, want none", client.registry.changed)
+	}
+}
+
+func TestDeviceCacheFile(t *testing.T) {
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	fileName := filepath.Join(t.TempDir(), "devices.json")
+
+	first := newFakeAccClient(t, server, WithDeviceCacheFile(fileName))
+	if _, err := first.GetDevices(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("cache file mode = %v, want 0600", info.Mode().Perm())
+	}
+	var snapshot registrySnapshot
+	data, _ := os.ReadFile(fileName)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.FetchedAt.Equal(testNow) || len(snapshot.Groups) != 1 || snapshot.Groups[0].DeviceList[0].DeviceGuid != "guid-1" {
+		t.Errorf("unexpected cache file %s", data)
+	}
+
+	// Another client reuses the file while it is fresh.
+	second := newFakeAccClient(t, server, WithDeviceCacheFile(fileName))
+	devices, err := second.GetDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].DeviceName != "Living room" || server.groupRequestCount() != 1 {
+		t.Errorf("got %v after %d group requests, want the cached device list", devices, server.groupRequestCount())
+	}
+
+	// An expired file is replaced.
+	clock := &manualClock{now: testNow.Add(DefaultDeviceCacheTTL)}
+	expired := newFakeAccClient(t, server, WithClock(clock), WithDeviceCacheFile(fileName))
+	if _, err := expired.GetDevices(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(fileName)
+	if err := json.Unmarshal(data, &snapshot); err != nil || !snapshot.FetchedAt.Equal(clock.Now()) ||
+		server.groupRequestCount() != 2 {
+		t.Errorf("expired cache file not refreshed: %s", data)
+	}
+
+	if err := expired.InvalidateDevices(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cache file not removed: %v", err)
+	}
+}
+
+func TestDeviceCacheFileInvalid(t *testing.T) {
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	fileName := filepath.Join(t.TempDir(), "devices.json")
+	if err := os.WriteFile(fileName, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeAccClient(t, server, WithDeviceCacheFile(fileName))
+
+	devices, err := client.GetDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || server.groupRequestCount() != 1 {
+		t.Errorf("got %v after %d group requests, want the fetched device list", devices, server.groupRequestCount())
+	}
+}
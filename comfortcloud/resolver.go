@@ -0,0 +1,129 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// AmbiguousDeviceError is returned when a device query matches more than one device.
+type AmbiguousDeviceError struct {
+	Query      string
+	Candidates []string
+}
+
+func (e *AmbiguousDeviceError) Error() string {
+	return fmt.Sprintf("device %q is ambiguous, candidates: %s", e.Query, strings.Join(e.Candidates, ", "))
+}
+
+// LoadAliases reads a JSON file mapping short names to a DeviceGuid or DeviceHashGuid, e.g.
+// {"living": "CS-Z25XKEW+1234567890"}.
+func LoadAliases(fileName string) (map[string]string, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alias file: %w", err)
+	}
+	var aliases map[string]string
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse alias file: %w", err)
+	}
+	return aliases, nil
+}
+
+// SetAliases replaces the aliases used to resolve devices. Alias names are case-insensitive.
+func (c *Client) SetAliases(aliases map[string]string) {
+	c.aliases = make(map[string]string, len(aliases))
+	for name, target := range aliases {
+		c.aliases[strings.ToLower(name)] = target
+	}
+}
+
+// LoadAliasFile loads the aliases from the given file, see LoadAliases.
+func (c *Client) LoadAliasFile(fileName string) error {
+	aliases, err := LoadAliases(fileName)
+	if err != nil {
+		return err
+	}
+	c.SetAliases(aliases)
+	return nil
+}
+
+// ResolveDevice finds a device by DeviceGuid, DeviceHashGuid, alias, DeviceName or
+// a unique prefix of a DeviceName. Name matching is case-insensitive.
+// The device list is fetched on first use; a cached list is refreshed once if the device is not found.
+func (c *Client) ResolveDevice(query string) (*Device, error) {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+
+	c.registry.loadFile()
+	fromCache := c.registry.isFresh(c.clock.Now())
+	if err := c.ensureDevices(); err != nil {
+		return nil, err
+	}
+	device, err := resolveDevice(c.registry.devices, c.aliases, query)
+	if errors.Is(err, ErrDeviceNotFound) && fromCache {
+		if err := c.refreshDevices(); err != nil {
+			return nil, err
+		}
+		return resolveDevice(c.registry.devices, c.aliases, query)
+	}
+	return device, err
+}
+
+func resolveDevice(devices []Device, aliases map[string]string, query string) (*Device, error) {
+	if device := findDeviceByID(devices, query); device != nil {
+		return device, nil
+	}
+
+	if target, ok := aliases[strings.ToLower(query)]; ok {
+		if device := findDeviceByID(devices, target); device != nil {
+			return device, nil
+		}
+		return nil, fmt.Errorf("%w: alias %s points to %s", ErrDeviceNotFound, query, target)
+	}
+
+	lowerQuery := strings.ToLower(query)
+	var exact, prefix []*Device
+	for i := range devices {
+		name := strings.ToLower(devices[i].DeviceName)
+		if name == lowerQuery {
+			exact = append(exact, &devices[i])
+		} else if lowerQuery != "" && strings.HasPrefix(name, lowerQuery) {
+			prefix = append(prefix, &devices[i])
+		}
+	}
+
+	for _, matches := range [][]*Device{exact, prefix} {
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			device := *matches[0]
+			return &device, nil
+		default:
+			candidates := make([]string, 0, len(matches))
+			for _, d := range matches {
+				candidates = append(candidates, fmt.Sprintf("%s (%s)", d.DeviceName, d.DeviceGuid))
+			}
+			return nil, &AmbiguousDeviceError{Query: query, Candidates: candidates}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, query)
+}
+
+func findDeviceByID(devices []Device, deviceID string) *Device {
+	if deviceID == "" {
+		return nil
+	}
+	for _, d := range devices {
+		if d.DeviceHashGuid == deviceID || d.DeviceGuid == deviceID {
+			return &d
+		}
+	}
+	return nil
+}
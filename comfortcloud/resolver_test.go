@@ -0,0 +1,155 @@
+package comfortcloud
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testDevices() []Device {
+	return []Device{
+		{DeviceGuid: "CS-Z25XKEW+1234567890", DeviceHashGuid: "hash-living", DeviceName: "Living room"},
+		{DeviceGuid: "CS-Z20XKEW+2345678901", DeviceHashGuid: "hash-bed-1", DeviceName: "Bedroom 1"},
+		{DeviceGuid: "CS-Z20XKEW+3456789012", DeviceHashGuid: "hash-bed-2", DeviceName: "Bedroom 2"},
+		{DeviceGuid: "CS-Z35XKEW+4567890123", DeviceName: "Bed"},
+		{DeviceGuid: "CS-Z25XKEW+5678901234", DeviceName: "Office"},
+		{DeviceGuid: "CS-Z25XKEW+6789012345", DeviceName: "office"},
+	}
+}
+
+func TestResolveDevice(t *testing.T) {
+	aliases := map[string]string{
+		"living":  "hash-living",
+		"kid":     "CS-Z20XKEW+2345678901",
+		"gone":    "CS-Z25XKEW+0000000000",
+		"bedroom": "CS-Z20XKEW+3456789012",
+	}
+	tests := []struct {
+		name       string
+		query      string
+		want       string
+		notFound   bool
+		candidates int
+	}{
+		{name: "guid", query: "CS-Z20XKEW+3456789012", want: "CS-Z20XKEW+3456789012"},
+		{name: "hash guid", query: "hash-bed-1", want: "CS-Z20XKEW+2345678901"},
+		{name: "alias to hash guid", query: "living", want: "CS-Z25XKEW+1234567890"},
+		{name: "alias is case-insensitive", query: "KID", want: "CS-Z20XKEW+2345678901"},
+		{name: "alias before name prefix", query: "bedroom", want: "CS-Z20XKEW+3456789012"},
+		{name: "alias to unknown device", query: "gone", notFound: true},
+		{name: "name", query: "Living room", want: "CS-Z25XKEW+1234567890"},
+		{name: "name is case-insensitive", query: "LIVING ROOM", want: "CS-Z25XKEW+1234567890"},
+		{name: "exact name before prefix", query: "bed", want: "CS-Z35XKEW+4567890123"},
+		{name: "unique prefix", query: "liv", want: "CS-Z25XKEW+1234567890"},
+		{name: "ambiguous prefix", query: "bedr", candidates: 2},
+		{name: "ambiguous name", query: "office", candidates: 2},
+		{name: "unknown", query: "kitchen", notFound: true},
+		{name: "empty", query: "", notFound: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			device, err := resolveDevice(testDevices(), aliases, test.query)
+			var ambiguous *AmbiguousDeviceError
+			switch {
+			case test.notFound:
+				if !errors.Is(err, ErrDeviceNotFound) {
+					t.Errorf("got %v, %v, want ErrDeviceNotFound", device, err)
+				}
+			case test.candidates > 0:
+				if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != test.candidates ||
+					ambiguous.Query != test.query {
+					t.Errorf("got %v, %v, want an ambiguous device error with %d candidates", device, err,
+						test.candidates)
+				}
+			case err != nil:
+				t.Errorf("unexpected error %v", err)
+			case device.DeviceGuid != test.want:
+				t.Errorf("got %s, want %s", device.DeviceGuid, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveDeviceReturnsCopy(t *testing.T) {
+	devices := testDevices()
+	device, err := resolveDevice(devices, nil, "liv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.DeviceName = "changed"
+	if devices[0].DeviceName != "Living room" {
+		t.Error("resolved device shares memory with the device list")
+	}
+}
+
+func TestLoadAliasFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+	valid := write("aliases.json", `{"Living": "hash-living", "kid": "CS-Z20XKEW+2345678901"}`)
+
+	aliases, err := LoadAliases(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 2 || aliases["Living"] != "hash-living" {
+		t.Errorf("got aliases %v", aliases)
+	}
+
+	client := newListedClient(testDevices()...)
+	if err := client.LoadAliasFile(valid); err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]string{"living": "CS-Z25XKEW+1234567890", "KID": "CS-Z20XKEW+2345678901"} {
+		if device, err := client.ResolveDevice(query); err != nil || device.DeviceGuid != want {
+			t.Errorf("ResolveDevice(%s) = %v, %v, want %s", query, device, err, want)
+		}
+	}
+
+	for name, fileName := range map[string]string{
+		"missing": filepath.Join(dir, "missing.json"),
+		"invalid": write("invalid.json", `["living"]`),
+	} {
+		if err := client.LoadAliasFile(fileName); err == nil {
+			t.Errorf("%s alias file loaded", name)
+		}
+	}
+	// A failed load keeps the previous aliases.
+	if _, err := client.ResolveDevice("living"); err != nil {
+		t.Errorf("aliases lost after failed load: %v", err)
+	}
+}
+
+func TestResolveDeviceRefreshesOnce(t *testing.T) {
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room"})
+	client := newFakeAccClient(t, server)
+
+	if _, err := client.ResolveDevice("Living room"); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	server.groups[0].DeviceList = append(server.groups[0].DeviceList, Device{DeviceGuid: "guid-2", DeviceName: "Bedroom"})
+	server.mu.Unlock()
+
+	// The cached list is used for known devices and refreshed for unknown ones.
+	if _, err := client.ResolveDevice("Living room"); err != nil {
+		t.Fatal(err)
+	}
+	if device, err := client.ResolveDevice("Bedroom"); err != nil || device.DeviceGuid != "guid-2" {
+		t.Errorf("got %v, %v, want the added device", device, err)
+	}
+	if _, err := client.ResolveDevice("Kitchen"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("got %v, want ErrDeviceNotFound", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.groupRequests != 3 {
+		t.Errorf("got %d group requests, want 3", server.groupRequests)
+	}
+}
@@ -0,0 +1,96 @@
+package comfortcloud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultSignerHeaders(t *testing.T) {
+	clock := ClockFunc(func() time.Time { return time.Date(2024, 3, 15, 12, 34, 56, 789000000, time.UTC) })
+	tests := []struct {
+		name      string
+		input     SigningInput
+		apiKey    string
+		timestamp string
+	}{
+		{
+			name:      "plain token",
+			input:     SigningInput{Time: clock.Now(), AccessToken: "token-a", AppVersion: "1.21.0"},
+			apiKey:    "e4cdd1e39cfc7ca310dd1c29cd8294da722440674a8e0542c26216eb0dcc5008828",
+			timestamp: "2024-03-15 12:34:56",
+		},
+		{
+			name: "jwt",
+			input: SigningInput{Time: clock.Now(), AccessToken: "eyJhbGciOiJIUzI1NiJ9.eyJpYXQiOjB9.sig",
+				AccClientID: "client-1", AppVersion: "1.21.0"},
+			apiKey:    "043ce8859cfce50c44cb3aa41bb525a78d5df462070f03c6b2594bce06d80c9acf1",
+			timestamp: "2024-03-15 12:34:56",
+		},
+		{
+			// The app signs the local wall clock time as if it were UTC.
+			name: "local time",
+			input: SigningInput{Time: time.Date(2024, 3, 15, 12, 34, 56, 0, time.FixedZone("CEST", 2*3600)),
+				AccessToken: "token-a"},
+			apiKey:    "e4cdd1e39cfc7ca310dd1c29cd8294da722440674a8e0542c26216eb0dcc5008828",
+			timestamp: "2024-03-15 12:34:56",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := DefaultSigner{}.Headers(test.input)
+			if got := headers["x-cfc-api-key"]; got != test.apiKey {
+				t.Errorf("x-cfc-api-key = %s, want %s", got, test.apiKey)
+			}
+			if got := headers["x-app-timestamp"]; got != test.timestamp {
+				t.Errorf("x-app-timestamp = %s, want %s", got, test.timestamp)
+			}
+			if got := headers["x-user-authorization-v2"]; got != "Bearer "+test.input.AccessToken {
+				t.Errorf("x-user-authorization-v2 = %s", got)
+			}
+			if got, ok := headers["x-client-id"]; ok != (test.input.AccClientID != "") || got != test.input.AccClientID {
+				t.Errorf("x-client-id = %q, want %q", got, test.input.AccClientID)
+			}
+		})
+	}
+}
+
+type staticSigner map[string]string
+
+func (s staticSigner) Headers(SigningInput) map[string]string { return s }
+
+func TestRequestSigner(t *testing.T) {
+	versioned := staticSigner{"x-cfc-api-key": "versioned"}
+	named := staticSigner{"x-cfc-api-key": "named"}
+	RegisterSigner("99.0.0", versioned)
+	RegisterSigner("test-named", named)
+
+	tests := []struct {
+		name       string
+		options    []AuthOption
+		appVersion string
+		want       Signer
+	}{
+		{name: "default", appVersion: "1.21.0", want: DefaultSigner{}},
+		{name: "by app version", appVersion: "99.0.0", want: versioned},
+		{name: "by name", options: []AuthOption{WithSignerName("test-named")}, appVersion: "99.0.0", want: named},
+		{name: "explicit", options: []AuthOption{WithSigner(named)}, appVersion: "99.0.0", want: named},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := NewAuthentication("", "", nil, test.options...)
+			signer, err := auth.requestSigner(test.appVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, want := signer.Headers(SigningInput{})["x-cfc-api-key"], test.want.Headers(SigningInput{})["x-cfc-api-key"]
+			if got != want {
+				t.Errorf("signer produced %s, want %s", got, want)
+			}
+		})
+	}
+
+	auth := NewAuthentication("", "", nil, WithSignerName("unknown"))
+	if _, err := auth.newAPIRequest("GET", "http://localhost", nil, SigningInput{}); err == nil {
+		t.Error("request with unknown signer succeeded")
+	}
+}
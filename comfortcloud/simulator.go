@@ -0,0 +1,276 @@
+package comfortcloud
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSimulatedLossTimeConstant is the time constant with which a simulated room approaches
+	// the outside temperature while the unit is off.
+	DefaultSimulatedLossTimeConstant = 6 * time.Hour
+	// DefaultSimulatedMaxRate is the heating or cooling rate of a simulated unit at full power in Kelvin per hour.
+	DefaultSimulatedMaxRate = 4.0
+
+	simulationStep = time.Minute
+)
+
+var (
+	_ DeviceController  = (*SimulatedDevice)(nil)
+	_ TemperatureSource = (*SimulatedDevice)(nil)
+	_ Clock             = (*SimulatedDevice)(nil)
+	_ RoomModel         = (*SimulatedDevice)(nil)
+)
+
+// simulatedFanFactor is the share of the full power a unit delivers at each fan speed.
+var simulatedFanFactor = map[FanSpeed]float64{
+	FanSpeedAuto:    1,
+	FanSpeedLow:     0.4,
+	FanSpeedLowMid:  0.55,
+	FanSpeedMid:     0.7,
+	FanSpeedHighMid: 0.85,
+	FanSpeedHigh:    1,
+}
+
+// SimulatedDevice is an in-memory device with a simple thermal model of the room it is in. It implements
+// DeviceController, TemperatureSource and Clock, so automations such as a Thermostat can run against it
+// without hardware, and RoomModel, so it can be passed to SimulateThermostat. Time only passes when Elapse
+// or Advance is called.
+type SimulatedDevice struct {
+	// LossTimeConstant is the time constant with which the room approaches OutTemperature.
+	LossTimeConstant time.Duration
+	// MaxRate is the heating or cooling rate at full power in Kelvin per hour.
+	MaxRate float64
+	// SensorOffset is added to the room temperature for the InsideTemperature reported by the unit,
+	// e.g. because its sensor is mounted near the ceiling.
+	SensorOffset Temperature
+
+	mu      sync.Mutex
+	device  Device
+	room    float64
+	now     time.Time
+	offline bool
+	seen    time.Time
+}
+
+// NewSimulatedDevice creates a simulated device from a device description, e.g. one returned by
+// Client.GetDevice. The room starts at the device's InsideTemperature.
+func NewSimulatedDevice(device Device) *SimulatedDevice {
+	room := device.Parameters.InsideTemperature.Celsius()
+	if !device.Parameters.InsideTemperature.Available() {
+		room = 20
+	}
+	if !device.Parameters.OutTemperature.Available() {
+		device.Parameters.OutTemperature = Celsius(room)
+	}
+	s := &SimulatedDevice{
+		LossTimeConstant: DefaultSimulatedLossTimeConstant,
+		MaxRate:          DefaultSimulatedMaxRate,
+		device:           device,
+		room:             room,
+		now:              time.Unix(0, 0).UTC(),
+	}
+	s.updateInsideTemperature()
+	return s
+}
+
+// GetDevice returns the current state of the device. deviceID must be empty or match the device.
+func (s *SimulatedDevice) GetDevice(deviceID string) (*Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkID(deviceID); err != nil {
+		return nil, err
+	}
+	if !s.offline {
+		s.seen = s.now
+	}
+	device := s.device
+	device.Online = !s.offline
+	device.LastSeen = s.seen
+	return &device, nil
+}
+
+// SetDevice applies the options to the device's parameters.
+func (s *SimulatedDevice) SetDevice(deviceID string, options ...DeviceOption) error {
+	parameter := &ParameterOptions{}
+	for _, option := range options {
+		option(parameter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkID(deviceID); err != nil {
+		return err
+	}
+	if s.offline {
+		return fmt.Errorf("%w: %s", ErrDeviceOffline, s.device.DeviceName)
+	}
+	s.device.Parameters = parameter.apply(s.device.Parameters)
+	s.updateInsideTemperature()
+	return nil
+}
+
+// ReadTemperature returns the room temperature as an external sensor would measure it.
+func (s *SimulatedDevice) ReadTemperature(_ context.Context) (Temperature, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Celsius(s.room), nil
+}
+
+// SetOutTemperature changes the outside temperature, e.g. to simulate the course of a day.
+func (s *SimulatedDevice) SetOutTemperature(temperature Temperature) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.device.Parameters.OutTemperature = Celsius(temperature.Celsius())
+}
+
+// SetOnline simulates the unit losing or regaining its connection. While offline, GetDevice reports the
+// device as offline since the time the connection was lost and SetDevice fails with ErrDeviceOffline.
+func (s *SimulatedDevice) SetOnline(online bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !online && !s.offline {
+		s.seen = s.now
+	}
+	s.offline = !online
+}
+
+// Now returns the simulated time.
+func (s *SimulatedDevice) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Elapse lets dt of simulated time pass and returns the new room temperature.
+func (s *SimulatedDevice) Elapse(dt time.Duration) Temperature {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.elapseLocked(dt)
+}
+
+// Advance sets TemperatureSet to setpoint and lets dt pass. The other parameters, e.g. whether the unit
+// is on and its operation mode, are those of the device.
+func (s *SimulatedDevice) Advance(setpoint Temperature, dt time.Duration) Temperature {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.device.Parameters.TemperatureSet = setpoint
+	return s.elapseLocked(dt)
+}
+
+// elapseLocked lets dt pass. The caller holds s.mu.
+func (s *SimulatedDevice) elapseLocked(dt time.Duration) Temperature {
+	for dt > 0 {
+		step := min(dt, simulationStep)
+		s.room += s.rate() * step.Hours()
+		s.now = s.now.Add(step)
+		dt -= step
+	}
+	s.updateInsideTemperature()
+	return Celsius(s.room)
+}
+
+// rate returns the change of the room temperature in Kelvin per hour. The caller holds s.mu.
+func (s *SimulatedDevice) rate() float64 {
+	parameters := s.device.Parameters
+	rate := 0.0
+	if s.LossTimeConstant > 0 {
+		rate = (parameters.OutTemperature.Celsius() - s.room) / s.LossTimeConstant.Hours()
+	}
+	if parameters.Operate != PowerOn {
+		return rate
+	}
+
+	// The unit regulates on its own sensor and throttles down within 1 K of the setpoint.
+	deviation := parameters.TemperatureSet.Celsius() - (s.room + s.SensorOffset.Celsius())
+	power := s.MaxRate * simulatedFanFactor[parameters.FanSpeed] * math.Max(-1, math.Min(1, deviation))
+	switch parameters.OperationMode {
+	case OperationModeHeat:
+		rate += math.Max(power, 0)
+	case OperationModeCool:
+		rate += math.Min(power, 0)
+	case OperationModeDry:
+		rate += math.Min(power, 0) / 2
+	case OperationModeAuto:
+		rate += power
+	}
+	return rate
+}
+
+// updateInsideTemperature sets InsideTemperature from the room temperature. The caller holds s.mu.
+func (s *SimulatedDevice) updateInsideTemperature() {
+	s.device.Parameters.InsideTemperature = Celsius(s.room + s.SensorOffset.Celsius())
+}
+
+func (s *SimulatedDevice) checkID(deviceID string) error {
+	if deviceID != "" && deviceID != s.device.DeviceGuid && deviceID != s.device.DeviceHashGuid &&
+		deviceID != s.device.DeviceName {
+		return fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceID)
+	}
+	return nil
+}
+
+// apply returns the parameters with the set options applied.
+func (o *ParameterOptions) apply(p Parameters) Parameters {
+	if o.Operate != nil {
+		p.Operate = *o.Operate
+	}
+	if o.OperationMode != nil {
+		p.OperationMode = *o.OperationMode
+	}
+	if o.TemperatureSet != nil {
+		p.TemperatureSet = *o.TemperatureSet
+	}
+	if o.FanSpeed != nil {
+		p.FanSpeed = *o.FanSpeed
+	}
+	if o.FanAutoMode != nil {
+		p.FanAutoMode = *o.FanAutoMode
+	}
+	if o.AirSwingLR != nil {
+		p.AirSwingLR = *o.AirSwingLR
+	}
+	if o.AirSwingUD != nil {
+		p.AirSwingUD = *o.AirSwingUD
+	}
+	if o.EcoFunctionData != nil {
+		p.EcoFunctionData = *o.EcoFunctionData
+	}
+	if o.EcoMode != nil {
+		p.EcoMode = *o.EcoMode
+	}
+	if o.EcoNavi != nil {
+		p.EcoNavi = *o.EcoNavi
+	}
+	if o.Nanoe != nil {
+		p.Nanoe = *o.Nanoe
+	}
+	if o.IAuto != nil {
+		p.IAuto = *o.IAuto
+	}
+	if o.AirDirection != nil {
+		p.AirDirection = *o.AirDirection
+	}
+	if o.LastSettingMode != nil {
+		p.LastSettingMode = *o.LastSettingMode
+	}
+	if o.InsideCleaning != nil {
+		p.InsideCleaning = *o.InsideCleaning
+	}
+	if o.Fireplace != nil {
+		p.Fireplace = *o.Fireplace
+	}
+	if o.InsideTemperature != nil {
+		p.InsideTemperature = *o.InsideTemperature
+	}
+	if o.OutTemperature != nil {
+		p.OutTemperature = *o.OutTemperature
+	}
+	if o.AirQuality != nil {
+		p.AirQuality = *o.AirQuality
+	}
+	return p
+}
@@ -0,0 +1,113 @@
+package comfortcloud
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// statusCache keeps the last device status per DeviceGuid. Entries younger than maxAge are served
+// directly, entries younger than maxAge+staleAge are served while a refresh runs in the background.
+// Concurrent fetches for the same device share one request. Devices are copied when they are stored and
+// returned, so callers may modify the returned devices.
+type statusCache struct {
+	mu         sync.Mutex
+	maxAge     time.Duration
+	staleAge   time.Duration
+	entries    map[string]*statusEntry
+	inflight   map[string]*statusCall
+	generation map[string]int
+	clock      Clock
+}
+
+type statusEntry struct {
+	device    Device
+	fetchedAt time.Time
+}
+
+type statusCall struct {
+	done   chan struct{}
+	device *Device
+	err    error
+}
+
+type statusFetcher func(device Device) (*Device, error)
+
+func newStatusCache(maxAge, staleAge time.Duration) *statusCache {
+	return &statusCache{
+		maxAge:     maxAge,
+		staleAge:   staleAge,
+		entries:    make(map[string]*statusEntry),
+		inflight:   make(map[string]*statusCall),
+		generation: make(map[string]int),
+		clock:      systemClock{},
+	}
+}
+
+func (s *statusCache) get(device Device, fetch statusFetcher) (*Device, error) {
+	guid := device.DeviceGuid
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	if entry, ok := s.entries[guid]; ok {
+		age := now.Sub(entry.fetchedAt)
+		if age < s.maxAge {
+			cached := entry.device.clone()
+			s.mu.Unlock()
+			return &cached, nil
+		}
+		if age < s.maxAge+s.staleAge {
+			s.startLocked(device, fetch)
+			cached := entry.device.clone()
+			s.mu.Unlock()
+			return &cached, nil
+		}
+	}
+	call := s.startLocked(device, fetch)
+	s.mu.Unlock()
+
+	<-call.done
+	if call.err != nil {
+		return nil, call.err
+	}
+	result := call.device.clone()
+	return &result, nil
+}
+
+// startLocked returns the running fetch for the device or starts a new one. The caller holds s.mu.
+func (s *statusCache) startLocked(device Device, fetch statusFetcher) *statusCall {
+	guid := device.DeviceGuid
+	if call, ok := s.inflight[guid]; ok {
+		return call
+	}
+	call := &statusCall{done: make(chan struct{})}
+	s.inflight[guid] = call
+	generation := s.generation[guid]
+
+	go func() {
+		call.device, call.err = fetch(device)
+
+		s.mu.Lock()
+		if s.generation[guid] == generation {
+			if call.err == nil {
+				s.entries[guid] = &statusEntry{device: call.device.clone(), fetchedAt: s.clock.Now()}
+			}
+			delete(s.inflight, guid)
+		}
+		s.mu.Unlock()
+
+		if call.err != nil {
+			slog.Debug("Device status refresh failed", "device", guid, "error", call.err)
+		}
+		close(call.done)
+	}()
+	return call
+}
+
+func (s *statusCache) invalidate(guid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, guid)
+	delete(s.inflight, guid)
+	s.generation[guid]++
+}
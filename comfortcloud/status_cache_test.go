@@ -0,0 +1,337 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAccServer is a fake Comfort Cloud API with a group listing, device statuses and a control endpoint
+// that applies the parameters to the device status.
+type fakeAccServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	groups   []Group
+	statuses map[string]*Device
+	// statusErrors maps device GUIDs to the error body returned for their status with status code 500.
+	statusErrors map[string]string
+	// statusHook is called before a status is returned.
+	statusHook     func(guid string)
+	groupRequests  int
+	statusRequests map[string]int
+	controls       []json.RawMessage
+}
+
+func newFakeAccServer(t *testing.T, devices ...Device) *fakeAccServer {
+	s := &fakeAccServer{
+		groups:         []Group{{GroupName: "Home", DeviceList: devices}},
+		statuses:       make(map[string]*Device),
+		statusErrors:   make(map[string]string),
+		statusRequests: make(map[string]int),
+	}
+	for _, device := range devices {
+		status := device
+		if status.Timestamp == 0 {
+			status.Timestamp = testNow.UnixMilli()
+		}
+		s.statuses[device.DeviceGuid] = &status
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeAccServer) handle(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/device/group":
+		s.mu.Lock()
+		s.groupRequests++
+		data, _ := json.Marshal(Response{GroupList: s.groups})
+		s.mu.Unlock()
+		_, _ = w.Write(data)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/deviceStatus/"):
+		guid := strings.TrimPrefix(r.URL.Path, "/deviceStatus/")
+		s.mu.Lock()
+		s.statusRequests[guid]++
+		hook := s.statusHook
+		s.mu.Unlock()
+		if hook != nil {
+			hook(guid)
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if body, ok := s.statusErrors[guid]; ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		status, ok := s.statuses[guid]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, _ := json.Marshal(status)
+		_, _ = w.Write(data)
+	case r.Method == http.MethodPost && r.URL.Path == "/deviceStatus/control":
+		var body struct {
+			DeviceGuid string          `json:"deviceGuid"`
+			Parameters json.RawMessage `json:"parameters"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.controls = append(s.controls, body.Parameters)
+		if status, ok := s.statuses[body.DeviceGuid]; ok {
+			_ = json.Unmarshal(body.Parameters, &status.Parameters)
+		}
+		_, _ = w.Write([]byte(`{"result":0}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *fakeAccServer) statusRequestCount(guid string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusRequests[guid]
+}
+
+func (s *fakeAccServer) groupRequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.groupRequests
+}
+
+// newFakeAccClient returns a client that is logged in to the fake server.
+func newFakeAccClient(t *testing.T, server *fakeAccServer, options ...ClientOption) *Client {
+	token := Token{
+		AccessToken:          testJWT(testNow, testNow.Add(time.Hour)),
+		AccessTokenIssuedAt:  testNow.Unix(),
+		AccessTokenExpiresAt: testNow.Add(time.Hour).Unix(),
+		RefreshToken:         "refresh",
+		AccClientID:          "acc-client",
+	}
+	data, _ := json.Marshal(token)
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(fileName, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	options = append([]ClientOption{WithClock(testClock()), WithAuthOptions(WithAccBasePath(server.URL))}, options...)
+	client := NewClient("", "", fileName, options...)
+	if err := client.Login(); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// manualClock is a Clock that only moves when advanced.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// countingFetcher returns a device named after the number of fetches so far.
+type countingFetcher struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (f *countingFetcher) fetch(device Device) (*Device, error) {
+	n := f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	device.DeviceName = fmt.Sprintf("fetch %d", n)
+	return &device, nil
+}
+
+func TestStatusCacheCoalescesRequests(t *testing.T) {
+	cache := newStatusCache(time.Minute, 0)
+	cache.clock = testClock()
+	fetcher := &countingFetcher{release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			device, err := cache.get(Device{DeviceGuid: "guid-1"}, fetcher.fetch)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = device.DeviceName
+		}(i)
+	}
+	// Let the first fetch wait until the other callers had the chance to join it.
+	time.Sleep(10 * time.Millisecond)
+	close(fetcher.release)
+	wg.Wait()
+
+	if calls := fetcher.calls.Load(); calls != 1 {
+		t.Errorf("got %d fetches, want 1", calls)
+	}
+	for _, name := range results {
+		if name != "fetch 1" {
+			t.Errorf("got %v, want all results from the first fetch", results)
+			break
+		}
+	}
+}
+
+func TestStatusCacheStaleWhileRevalidate(t *testing.T) {
+	clock := &manualClock{now: testNow}
+	cache := newStatusCache(time.Minute, time.Minute)
+	cache.clock = clock
+	fetcher := &countingFetcher{}
+	get := func() string {
+		t.Helper()
+		device, err := cache.get(Device{DeviceGuid: "guid-1"}, fetcher.fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return device.DeviceName
+	}
+	waitForRefresh := func() {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			cache.mu.Lock()
+			_, running := cache.inflight["guid-1"]
+			cache.mu.Unlock()
+			if !running {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("background refresh did not finish")
+	}
+
+	if got := get(); got != "fetch 1" {
+		t.Fatalf("first get = %s", got)
+	}
+	clock.advance(30 * time.Second)
+	if got := get(); got != "fetch 1" || fetcher.calls.Load() != 1 {
+		t.Errorf("fresh get = %s after %d fetches, want the cached status", got, fetcher.calls.Load())
+	}
+
+	// A stale entry is returned at once and refreshed in the background.
+	clock.advance(time.Minute)
+	if got := get(); got != "fetch 1" {
+		t.Errorf("stale get = %s, want the cached status", got)
+	}
+	waitForRefresh()
+	if got := get(); got != "fetch 2" || fetcher.calls.Load() != 2 {
+		t.Errorf("get after refresh = %s after %d fetches, want fetch 2", got, fetcher.calls.Load())
+	}
+
+	// An entry older than maxAge+staleAge is not returned.
+	clock.advance(3 * time.Minute)
+	if got := get(); got != "fetch 3" {
+		t.Errorf("expired get = %s, want fetch 3", got)
+	}
+}
+
+func TestStatusCacheDoesNotStoreErrors(t *testing.T) {
+	cache := newStatusCache(time.Minute, 0)
+	cache.clock = testClock()
+	calls := 0
+	fetch := func(device Device) (*Device, error) {
+		calls++
+		return nil, errors.New("unavailable")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get(Device{DeviceGuid: "guid-1"}, fetch); err == nil {
+			t.Error("get succeeded")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d fetches, want 2", calls)
+	}
+}
+
+func TestStatusCacheCopiesDevices(t *testing.T) {
+	cache := newStatusCache(time.Minute, 0)
+	cache.clock = testClock()
+	fetched := &Device{DeviceGuid: "guid-1", Raw: json.RawMessage(`{"a":1}`),
+		Extra:      map[string]json.RawMessage{"new": json.RawMessage(`1`)},
+		Parameters: Parameters{Extra: map[string]json.RawMessage{"newParameter": json.RawMessage(`2`)}}}
+	fetch := func(device Device) (*Device, error) { return fetched, nil }
+
+	first, err := cache.get(Device{DeviceGuid: "guid-1"}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither the fetched device nor a returned device share memory with the cache.
+	fetched.Raw[1] = 'X'
+	fetched.Extra["new"] = json.RawMessage(`3`)
+	first.Extra["added"] = json.RawMessage(`4`)
+	first.Parameters.Extra["newParameter"][0] = '5'
+
+	second, err := cache.get(Device{DeviceGuid: "guid-1"}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(second.Raw) != `{"a":1}` || len(second.Extra) != 1 || string(second.Extra["new"]) != "1" ||
+		string(second.Parameters.Extra["newParameter"]) != "2" {
+		t.Errorf("cached device was modified: raw %s, extra %s, parameters %s", second.Raw, second.Extra,
+			second.Parameters.Extra)
+	}
+}
+
+func TestStatusCacheInvalidatedBySetDevice(t *testing.T) {
+	server := newFakeAccServer(t, Device{DeviceGuid: "guid-1", DeviceName: "Living room",
+		Parameters: Parameters{Operate: PowerOff, TemperatureSet: Celsius(20)}})
+	client := newFakeAccClient(t, server, WithStatusCache(time.Minute, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		device, err := client.GetDevice("guid-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if device.Parameters.Operate != PowerOff {
+			t.Errorf("operate = %v, want off", device.Parameters.Operate)
+		}
+	}
+	if got := server.statusRequestCount("guid-1"); got != 1 {
+		t.Errorf("got %d status requests before SetDevice, want 1", got)
+	}
+
+	if err := client.SetDevice("guid-1", WithPower(PowerOn)); err != nil {
+		t.Fatal(err)
+	}
+	device, err := client.GetDevice("guid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Parameters.Operate != PowerOn {
+		t.Errorf("operate after SetDevice = %v, want on", device.Parameters.Operate)
+	}
+	if got := server.statusRequestCount("guid-1"); got < 2 {
+		t.Errorf("got %d status requests, want a new request after SetDevice", got)
+	}
+}
@@ -0,0 +1,94 @@
+package comfortcloud
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultGroupStatusMaxAge = time.Minute
+	DefaultStatusConcurrency = 4
+)
+
+// DeviceStatus is the result of fetching the status of a single device.
+type DeviceStatus struct {
+	Device *Device
+	Err    error
+}
+
+// GetAllStatuses returns the status of every device keyed by DeviceGuid. The parameters embedded in the
+// group listing are used if the listing is younger than the group status max age and the device was not
+// controlled since, otherwise the devices are fetched individually with bounded concurrency. Per-device
+// failures are reported in DeviceStatus.Err; the returned error is only set if the device list itself
+// could not be obtained.
+func (c *Client) GetAllStatuses(ctx context.Context) (map[string]DeviceStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.registry.mu.Lock()
+	if err := c.ensureDevices(); err != nil {
+		c.registry.mu.Unlock()
+		return nil, err
+	}
+	devices := append([]Device(nil), c.registry.devices...)
+	fetchedAt := c.registry.fetchedAt
+	changed := make(map[string]bool, len(c.registry.changed))
+	for guid := range c.registry.changed {
+		changed[guid] = true
+	}
+	c.registry.mu.Unlock()
+
+	statuses := make(map[string]DeviceStatus, len(devices))
+	if c.groupStatusMaxAge > 0 && c.clock.Now().Sub(fetchedAt) <= c.groupStatusMaxAge {
+		var outdated []Device
+		for i := range devices {
+			if changed[devices[i].DeviceGuid] {
+				outdated = append(outdated, devices[i])
+				continue
+			}
+			c.setConnectivity(&devices[i])
+			statuses[devices[i].DeviceGuid] = DeviceStatus{Device: &devices[i]}
+		}
+		devices = outdated
+	}
+
+	concurrency := c.statusConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, device := range devices {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			mu.Lock()
+			statuses[device.DeviceGuid] = DeviceStatus{Err: ctx.Err()}
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(device Device) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			var status DeviceStatus
+			if c.status == nil {
+				status.Device, status.Err = c.fetchDeviceStatus(device)
+			} else {
+				status.Device, status.Err = c.status.get(device, c.fetchDeviceStatus)
+			}
+
+			mu.Lock()
+			statuses[device.DeviceGuid] = status
+			mu.Unlock()
+		}(device)
+	}
+	wg.Wait()
+
+	return statuses, nil
+}
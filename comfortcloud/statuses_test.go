@@ -0,0 +1,152 @@
+package comfortcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetAllStatusesConcurrency(t *testing.T) {
+	var devices []Device
+	for i := 1; i <= 6; i++ {
+		devices = append(devices, Device{DeviceGuid: fmt.Sprintf("guid-%d", i), DeviceName: fmt.Sprintf("Room %d", i)})
+	}
+	server := newFakeAccServer(t, devices...)
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	server.statusHook = func(string) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}
+	client := newFakeAccClient(t, server, WithGroupStatusMaxAge(0), WithStatusConcurrency(2))
+
+	statuses, err := client.GetAllStatuses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != len(devices) {
+		t.Errorf("got %d statuses, want %d", len(statuses), len(devices))
+	}
+	for _, device := range devices {
+		if got := server.statusRequestCount(device.DeviceGuid); got != 1 {
+			t.Errorf("%s: got %d status requests, want 1", device.DeviceGuid, got)
+		}
+	}
+	if maxActive > 2 {
+		t.Errorf("got %d parallel status requests, want at most 2", maxActive)
+	}
+}
+
+func TestGetAllStatusesCollectsDeviceErrors(t *testing.T) {
+	server := newFakeAccServer(t,
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom"},
+		Device{DeviceGuid: "guid-3", DeviceName: "Office"})
+	server.statusErrors["guid-2"] = `{"code":4100,"message":"internal error"}`
+	server.statusErrors["guid-3"] = fmt.Sprintf(`{"code":%d,"message":"device offline"}`, accCodeDeviceOffline)
+	client := newFakeAccClient(t, server, WithGroupStatusMaxAge(0))
+
+	statuses, err := client.GetAllStatuses(context.Background())
+	if err != nil {
+		t.Fatalf("per-device errors failed GetAllStatuses: %v", err)
+	}
+	if status := statuses["guid-1"]; status.Err != nil || status.Device == nil || status.Device.DeviceName != "Living room" {
+		t.Errorf("guid-1: got %+v", status)
+	}
+	var apiError *APIError
+	if status := statuses["guid-2"]; status.Device != nil || !errors.As(status.Err, &apiError) || apiError.Code != 4100 {
+		t.Errorf("guid-2: got %+v, want the API error", status)
+	}
+	if status := statuses["guid-3"]; status.Device != nil || !errors.Is(status.Err, ErrDeviceOffline) {
+		t.Errorf("guid-3: got %+v, want ErrDeviceOffline", status)
+	}
+}
+
+func TestGetAllStatusesFromListing(t *testing.T) {
+	server := newFakeAccServer(t,
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room", Parameters: Parameters{Operate: PowerOff}},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom", Parameters: Parameters{Operate: PowerOff}})
+	clock := &manualClock{now: testNow}
+	client := newFakeAccClient(t, server, WithClock(clock))
+	get := func() map[string]DeviceStatus {
+		t.Helper()
+		statuses, err := client.GetAllStatuses(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for guid, status := range statuses {
+			if status.Err != nil {
+				t.Fatalf("%s: %v", guid, status.Err)
+			}
+		}
+		return statuses
+	}
+	requests := func() (int, int) {
+		return server.statusRequestCount("guid-1"), server.statusRequestCount("guid-2")
+	}
+
+	// A fresh listing is used without status requests.
+	get()
+	if first, second := requests(); first != 0 || second != 0 {
+		t.Errorf("fresh listing: got %d and %d status requests, want none", first, second)
+	}
+
+	// A controlled device is fetched again, the others still come from the listing.
+	if err := client.SetDevice("guid-1", WithPower(PowerOn)); err != nil {
+		t.Fatal(err)
+	}
+	statuses := get()
+	if first, second := requests(); first != 1 || second != 0 {
+		t.Errorf("changed device: got %d and %d status requests, want 1 and 0", first, second)
+	}
+	if statuses["guid-1"].Device.Parameters.Operate != PowerOn || statuses["guid-2"].Device.Parameters.Operate != PowerOff {
+		t.Errorf("changed device: got %v and %v", statuses["guid-1"].Device.Parameters.Operate,
+			statuses["guid-2"].Device.Parameters.Operate)
+	}
+
+	// A listing older than the group status max age is not used, although the device list is still cached.
+	clock.advance(DefaultGroupStatusMaxAge + time.Second)
+	get()
+	if first, second := requests(); first != 2 || second != 1 {
+		t.Errorf("stale listing: got %d and %d status requests, want 2 and 1", first, second)
+	}
+	if got := server.groupRequestCount(); got != 1 {
+		t.Errorf("got %d group requests, want 1", got)
+	}
+}
+
+func TestGetAllStatusesCancelled(t *testing.T) {
+	server := newFakeAccServer(t,
+		Device{DeviceGuid: "guid-1", DeviceName: "Living room"},
+		Device{DeviceGuid: "guid-2", DeviceName: "Bedroom"})
+	client := newFakeAccClient(t, server, WithGroupStatusMaxAge(0), WithStatusConcurrency(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	// The first request cancels the context and keeps the only slot, so the second device is not fetched.
+	server.statusHook = func(string) {
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	statuses, err := client.GetAllStatuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled := 0
+	for _, status := range statuses {
+		if errors.Is(status.Err, context.Canceled) {
+			cancelled++
+		}
+	}
+	if len(statuses) != 2 || cancelled != 1 {
+		t.Errorf("got %+v, want one status cancelled before its request", statuses)
+	}
+}
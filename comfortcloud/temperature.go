@@ -0,0 +1,99 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+type TemperatureUnit int
+
+const (
+	TemperatureUnitCelsius TemperatureUnit = iota
+	TemperatureUnitFahrenheit
+)
+
+// Temperature is a temperature value together with its unit. The Comfort Cloud API always uses Celsius,
+// so temperatures are converted to Celsius when encoded to JSON and decoded as Celsius, regardless of the
+// TemperatureUnit of the device. Use In to convert them for display.
+type Temperature struct {
+	Value float64
+	Unit  TemperatureUnit
+}
+
+// Values the API uses for temperatures and history values that are not available.
+const (
+	temperatureUnavailable  = 126
+	historyValueUnavailable = -255
+)
+
+func Celsius(value float64) Temperature {
+	return Temperature{Value: value, Unit: TemperatureUnitCelsius}
+}
+
+func Fahrenheit(value float64) Temperature {
+	return Temperature{Value: value, Unit: TemperatureUnitFahrenheit}
+}
+
+// Celsius returns the temperature in degrees Celsius.
+func (t Temperature) Celsius() float64 {
+	if t.Unit == TemperatureUnitFahrenheit {
+		return (t.Value - 32) * 5 / 9
+	}
+	return t.Value
+}
+
+// Fahrenheit returns the temperature in degrees Fahrenheit.
+func (t Temperature) Fahrenheit() float64 {
+	if t.Unit == TemperatureUnitFahrenheit {
+		return t.Value
+	}
+	return t.Value*9/5 + 32
+}
+
+// In converts the temperature to the given unit.
+func (t Temperature) In(unit TemperatureUnit) Temperature {
+	if unit == TemperatureUnitFahrenheit {
+		return Fahrenheit(t.Fahrenheit())
+	}
+	return Celsius(t.Celsius())
+}
+
+// Round rounds the temperature to the 0.5° steps accepted by the units.
+func (t Temperature) Round() Temperature {
+	return Temperature{Value: roundToHalf(t.Value), Unit: t.Unit}
+}
+
+// Available reports whether the temperature is a measured value rather than the placeholder the API
+// reports for missing sensors or history data.
+func (t Temperature) Available() bool {
+	celsius := t.Celsius()
+	return celsius != temperatureUnavailable && celsius != historyValueUnavailable
+}
+
+func (t Temperature) String() string {
+	if t.Unit == TemperatureUnitFahrenheit {
+		return fmt.Sprintf("%g°F", t.Value)
+	}
+	return fmt.Sprintf("%g°C", t.Value)
+}
+
+func (t Temperature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Celsius())
+}
+
+func (t *Temperature) UnmarshalJSON(data []byte) error {
+	var value *float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid temperature %s: %w", data, err)
+	}
+	*t = Celsius(0)
+	if value != nil {
+		t.Value = *value
+	}
+	return nil
+}
+
+func roundToHalf(value float64) float64 {
+	return math.Round(value*2) / 2
+}
@@ -0,0 +1,113 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestTemperatureConversion(t *testing.T) {
+	tests := []struct {
+		temperature Temperature
+		celsius     float64
+		fahrenheit  float64
+	}{
+		{Celsius(0), 0, 32},
+		{Celsius(21.5), 21.5, 70.7},
+		{Celsius(-40), -40, -40},
+		{Fahrenheit(212), 100, 212},
+		{Fahrenheit(70), 21.111111, 70},
+	}
+	for _, test := range tests {
+		if got := test.temperature.Celsius(); math.Abs(got-test.celsius) > 1e-6 {
+			t.Errorf("%s in Celsius = %g, want %g", test.temperature, got, test.celsius)
+		}
+		if got := test.temperature.Fahrenheit(); math.Abs(got-test.fahrenheit) > 1e-6 {
+			t.Errorf("%s in Fahrenheit = %g, want %g", test.temperature, got, test.fahrenheit)
+		}
+		if got := test.temperature.In(TemperatureUnitFahrenheit); got.Unit != TemperatureUnitFahrenheit ||
+			math.Abs(got.Value-test.fahrenheit) > 1e-6 {
+			t.Errorf("%s.In(Fahrenheit) = %s", test.temperature, got)
+		}
+		if got := test.temperature.In(TemperatureUnitCelsius); got.Unit != TemperatureUnitCelsius ||
+			math.Abs(got.Value-test.celsius) > 1e-6 {
+			t.Errorf("%s.In(Celsius) = %s", test.temperature, got)
+		}
+	}
+}
+
+func TestTemperatureRound(t *testing.T) {
+	tests := []struct {
+		temperature Temperature
+		want        Temperature
+	}{
+		{Celsius(21.2), Celsius(21)},
+		{Celsius(21.25), Celsius(21.5)},
+		{Celsius(21.74), Celsius(21.5)},
+		{Celsius(-0.3), Celsius(-0.5)},
+		{Fahrenheit(70.3), Fahrenheit(70.5)},
+	}
+	for _, test := range tests {
+		if got := test.temperature.Round(); got != test.want {
+			t.Errorf("%s.Round() = %s, want %s", test.temperature, got, test.want)
+		}
+	}
+}
+
+func TestWithTemperatureRoundsCelsius(t *testing.T) {
+	tests := []struct {
+		temperature Temperature
+		want        Temperature
+	}{
+		{Celsius(21.3), Celsius(21.5)},
+		{Celsius(21), Celsius(21)},
+		// 70°F is 21.1°C, 72°F is 22.2°C and 75°F is 23.9°C.
+		{Fahrenheit(70), Celsius(21)},
+		{Fahrenheit(72), Celsius(22)},
+		{Fahrenheit(75), Celsius(24)},
+	}
+	for _, test := range tests {
+		var options ParameterOptions
+		WithTemperature(test.temperature)(&options)
+		if got := *options.TemperatureSet; got != test.want {
+			t.Errorf("WithTemperature(%s) sets %s, want %s", test.temperature, got, test.want)
+		}
+	}
+}
+
+func TestTemperatureJSON(t *testing.T) {
+	data, err := json.Marshal(struct{ Set Temperature }{Fahrenheit(212)})
+	if err != nil || string(data) != `{"Set":100}` {
+		t.Errorf("got %s, %v, want the temperature in Celsius", data, err)
+	}
+
+	var decoded struct{ Set, Missing Temperature }
+	if err := json.Unmarshal([]byte(`{"Set":21.5,"Missing":null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Set != Celsius(21.5) || decoded.Missing != Celsius(0) {
+		t.Errorf("got %+v", decoded)
+	}
+}
+
+func TestDeviceTemperaturesAreCelsius(t *testing.T) {
+	var device Device
+	data := `{"deviceGuid":"guid-1","temperatureUnit":1,"parameters":{"temperatureSet":21,"insideTemperature":20.5,"outTemperature":126}}`
+	if err := json.Unmarshal([]byte(data), &device); err != nil {
+		t.Fatal(err)
+	}
+	if device.TemperatureUnit != TemperatureUnitFahrenheit {
+		t.Fatalf("unit = %s, want Fahrenheit", device.TemperatureUnit)
+	}
+	if device.Parameters.TemperatureSet != Celsius(21) || device.Parameters.InsideTemperature != Celsius(20.5) {
+		t.Errorf("parameters %+v not decoded in Celsius", device.Parameters)
+	}
+
+	display := device.Parameters.In(device.TemperatureUnit)
+	if display.TemperatureSet.String() != "69.8°F" || display.InsideTemperature.String() != "68.9°F" {
+		t.Errorf("display temperatures %s, %s", display.TemperatureSet, display.InsideTemperature)
+	}
+	if display.OutTemperature.Available() {
+		t.Errorf("unavailable outside temperature converted to %s", display.OutTemperature)
+	}
+}
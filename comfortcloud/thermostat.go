@@ -0,0 +1,366 @@
+package comfortcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceController reads and controls devices. It is implemented by Client and by simulated devices.
+type DeviceController interface {
+	GetDevice(deviceID string) (*Device, error)
+	SetDevice(deviceID string, options ...DeviceOption) error
+}
+
+var _ DeviceController = (*Client)(nil)
+
+// TemperatureSource provides the room temperature measured by an external sensor.
+type TemperatureSource interface {
+	ReadTemperature(ctx context.Context) (Temperature, error)
+}
+
+// TemperatureSourceFunc adapts a function to the TemperatureSource interface.
+type TemperatureSourceFunc func(ctx context.Context) (Temperature, error)
+
+func (f TemperatureSourceFunc) ReadTemperature(ctx context.Context) (Temperature, error) {
+	return f(ctx)
+}
+
+// FileTemperatureSource reads the temperature in Celsius from a file, e.g. one written by a sensor daemon.
+// The file contains either a plain number or a JSON object with a "temperature" field.
+type FileTemperatureSource struct {
+	FileName string
+}
+
+func (s FileTemperatureSource) ReadTemperature(_ context.Context) (Temperature, error) {
+	data, err := os.ReadFile(s.FileName)
+	if err != nil {
+		return Temperature{}, fmt.Errorf("failed to read temperature file: %w", err)
+	}
+	return parseTemperatureReading(data)
+}
+
+// HTTPTemperatureSource reads the temperature in Celsius from a URL returning the same formats
+// as FileTemperatureSource.
+type HTTPTemperatureSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s HTTPTemperatureSource) ReadTemperature(ctx context.Context) (Temperature, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return Temperature{}, fmt.Errorf("failed to create temperature request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Temperature{}, fmt.Errorf("temperature request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Temperature{}, fmt.Errorf("read_temperature: expected status 200, got %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Temperature{}, fmt.Errorf("failed to read temperature: %w", err)
+	}
+	return parseTemperatureReading(data)
+}
+
+func parseTemperatureReading(data []byte) (Temperature, error) {
+	var reading struct {
+		Temperature *float64 `json:"temperature"`
+	}
+	if json.Unmarshal(data, &reading) == nil && reading.Temperature != nil {
+		return Celsius(*reading.Temperature), nil
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil {
+		return Temperature{}, fmt.Errorf("invalid temperature reading %q", strings.TrimSpace(string(data)))
+	}
+	return Celsius(value), nil
+}
+
+// ControlInput is what a ControlStrategy decides on.
+type ControlInput struct {
+	Time     time.Time
+	Room     Temperature
+	Target   Temperature
+	Setpoint Temperature
+}
+
+// ControlStrategy computes the next setpoint of the unit from the measured room temperature.
+type ControlStrategy interface {
+	NextSetpoint(input ControlInput) Temperature
+}
+
+// HysteresisStrategy raises the setpoint by Step while the room is colder than Target-Band and lowers it
+// while the room is warmer than Target+Band.
+type HysteresisStrategy struct {
+	Band Temperature
+	Step Temperature
+}
+
+func (s *HysteresisStrategy) NextSetpoint(input ControlInput) Temperature {
+	room, target, setpoint := input.Room.Celsius(), input.Target.Celsius(), input.Setpoint.Celsius()
+	switch {
+	case room < target-s.Band.Celsius():
+		return Celsius(setpoint + s.Step.Celsius())
+	case room > target+s.Band.Celsius():
+		return Celsius(setpoint - s.Step.Celsius())
+	default:
+		return input.Setpoint
+	}
+}
+
+// PIStrategy sets the setpoint to Target + Kp*error + Ki*integral(error), where error is Target-Room
+// in Kelvin and the integral is in Kelvin hours. The integral is limited to ±IntegralLimit.
+type PIStrategy struct {
+	Kp            float64
+	Ki            float64
+	IntegralLimit float64
+
+	integral float64
+	last     time.Time
+}
+
+func (s *PIStrategy) NextSetpoint(input ControlInput) Temperature {
+	controlError := input.Target.Celsius() - input.Room.Celsius()
+	if !s.last.IsZero() {
+		hours := input.Time.Sub(s.last).Hours()
+		s.integral += controlError * hours
+		if s.IntegralLimit > 0 {
+			s.integral = math.Max(-s.IntegralLimit, math.Min(s.IntegralLimit, s.integral))
+		}
+	}
+	s.last = input.Time
+	return Celsius(input.Target.Celsius() + s.Kp*controlError + s.Ki*s.integral)
+}
+
+// Thermostat adjusts TemperatureSet of a device so that the temperature measured by an external
+// sensor reaches Target.
+type Thermostat struct {
+	Controller DeviceController
+	DeviceID   string
+	Source     TemperatureSource
+	Strategy   ControlStrategy
+	Target     Temperature
+
+	// MinSetpoint and MaxSetpoint limit the setpoint sent to the device.
+	MinSetpoint Temperature
+	MaxSetpoint Temperature
+	// MaxStep limits how much the setpoint changes per command. Zero means no limit.
+	MaxStep Temperature
+	// MinCommandInterval is the minimum time between two commands to the device.
+	MinCommandInterval time.Duration
+	// Interval is the time between two control steps of Run.
+	Interval time.Duration
+	Clock    Clock
+
+	setpoint    *Temperature
+	lastCommand time.Time
+}
+
+// ThermostatDecision describes one control step.
+type ThermostatDecision struct {
+	Time        time.Time
+	Room        Temperature
+	Setpoint    Temperature
+	NewSetpoint Temperature
+	Sent        bool
+}
+
+func (t *Thermostat) now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
+	}
+	return t.Clock.Now()
+}
+
+// Step reads the room temperature, computes the new setpoint and sends it to the device if it changed
+// and the last command is at least MinCommandInterval ago.
+func (t *Thermostat) Step(ctx context.Context) (ThermostatDecision, error) {
+	now := t.now()
+	room, err := t.Source.ReadTemperature(ctx)
+	if err != nil {
+		return ThermostatDecision{}, fmt.Errorf("failed to read room temperature: %w", err)
+	}
+	if t.setpoint == nil {
+		device, err := t.Controller.GetDevice(t.DeviceID)
+		if err != nil {
+			return ThermostatDecision{}, fmt.Errorf("failed to read setpoint: %w", err)
+		}
+		setpoint := Celsius(device.Parameters.TemperatureSet.Celsius())
+		t.setpoint = &setpoint
+	}
+
+	decision := ThermostatDecision{Time: now, Room: room, Setpoint: *t.setpoint}
+	next := t.Strategy.NextSetpoint(ControlInput{Time: now, Room: room, Target: t.Target, Setpoint: *t.setpoint})
+	decision.NewSetpoint = t.limit(next)
+
+	if decision.NewSetpoint.Celsius() == t.setpoint.Celsius() {
+		return decision, nil
+	}
+	if !t.lastCommand.IsZero() && now.Sub(t.lastCommand) < t.MinCommandInterval {
+		decision.NewSetpoint = *t.setpoint
+		return decision, nil
+	}
+	if err := t.Controller.SetDevice(t.DeviceID, WithTemperature(decision.NewSetpoint)); err != nil {
+		return decision, fmt.Errorf("failed to set setpoint: %w", err)
+	}
+	t.setpoint = &decision.NewSetpoint
+	t.lastCommand = now
+	decision.Sent = true
+	return decision, nil
+}
+
+// limit applies MaxStep, MinSetpoint and MaxSetpoint and rounds to the steps accepted by the units.
+func (t *Thermostat) limit(next Temperature) Temperature {
+	value := next.Celsius()
+	current := t.setpoint.Celsius()
+	if maxStep := t.MaxStep.Celsius(); maxStep > 0 {
+		value = math.Max(current-maxStep, math.Min(current+maxStep, value))
+	}
+	if t.MinSetpoint.Value != 0 {
+		value = math.Max(value, t.MinSetpoint.Celsius())
+	}
+	if t.MaxSetpoint.Value != 0 {
+		value = math.Min(value, t.MaxSetpoint.Celsius())
+	}
+	return Celsius(roundToHalf(value))
+}
+
+// Run calls Step every Interval until ctx is done. Failed steps are logged and retried at the next interval.
+func (t *Thermostat) Run(ctx context.Context) error {
+	if t.Interval <= 0 {
+		return errors.New("thermostat interval must be positive")
+	}
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		decision, err := t.Step(ctx)
+		if err != nil {
+			slog.Warn("Thermostat step failed", "device", t.DeviceID, "error", err)
+		} else {
+			slog.Debug("Thermostat step", "device", t.DeviceID, "room", decision.Room,
+				"setpoint", decision.NewSetpoint, "sent", decision.Sent)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RoomModel is a thermal model of a room used to tune a Thermostat offline.
+type RoomModel interface {
+	// Advance returns the room temperature after dt with the unit running at setpoint.
+	Advance(setpoint Temperature, dt time.Duration) Temperature
+}
+
+// FirstOrderRoom is a simple RoomModel: the room approaches setpoint+SensorOffset with time constant
+// TimeConstant, i.e. the unit's own sensor reads SensorOffset warmer than the room.
+type FirstOrderRoom struct {
+	Temperature  Temperature
+	SensorOffset Temperature
+	TimeConstant time.Duration
+}
+
+func (r *FirstOrderRoom) Advance(setpoint Temperature, dt time.Duration) Temperature {
+	goal := setpoint.Celsius() - r.SensorOffset.Celsius()
+	alpha := 1 - math.Exp(-dt.Seconds()/r.TimeConstant.Seconds())
+	current := r.Temperature.Celsius()
+	r.Temperature = Celsius(current + (goal-current)*alpha)
+	return r.Temperature
+}
+
+// SimulateThermostat runs the thermostat for steps intervals against a room model instead of a real
+// device and sensor, starting at initialSetpoint. The simulation runs on a copy of the thermostat's
+// configuration with fresh strategy state and never modifies t, so it can be simulated while it controls
+// a real device.
+func SimulateThermostat(t *Thermostat, room RoomModel, initialSetpoint Temperature, steps int) ([]ThermostatDecision, error) {
+	if t.Interval <= 0 {
+		return nil, errors.New("thermostat interval must be positive")
+	}
+
+	now := time.Unix(0, 0).UTC()
+	simulated := &simulatedSetpoint{setpoint: initialSetpoint}
+	var roomTemperature Temperature
+	sim := &Thermostat{
+		Controller:         simulated,
+		DeviceID:           t.DeviceID,
+		Source:             TemperatureSourceFunc(func(context.Context) (Temperature, error) { return roomTemperature, nil }),
+		Strategy:           freshStrategy(t.Strategy),
+		Target:             t.Target,
+		MinSetpoint:        t.MinSetpoint,
+		MaxSetpoint:        t.MaxSetpoint,
+		MaxStep:            t.MaxStep,
+		MinCommandInterval: t.MinCommandInterval,
+		Interval:           t.Interval,
+		Clock:              ClockFunc(func() time.Time { return now }),
+	}
+
+	decisions := make([]ThermostatDecision, 0, steps)
+	for i := 0; i < steps; i++ {
+		roomTemperature = room.Advance(simulated.setpoint, sim.Interval)
+		now = now.Add(sim.Interval)
+		decision, err := sim.Step(context.Background())
+		if err != nil {
+			return decisions, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
+
+// freshStrategy returns a new strategy with the exported configuration of a strategy that is a pointer to a
+// struct, without its internal state. The state of the original is not read, as it may be in use. Other
+// strategies are returned as they are.
+func freshStrategy(strategy ControlStrategy) ControlStrategy {
+	value := reflect.ValueOf(strategy)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return strategy
+	}
+	fresh := reflect.New(value.Elem().Type())
+	for i := 0; i < value.Elem().NumField(); i++ {
+		if value.Elem().Type().Field(i).IsExported() {
+			fresh.Elem().Field(i).Set(value.Elem().Field(i))
+		}
+	}
+	return fresh.Interface().(ControlStrategy)
+}
+
+// simulatedSetpoint is a DeviceController that only keeps the setpoint.
+type simulatedSetpoint struct {
+	setpoint Temperature
+}
+
+func (s *simulatedSetpoint) GetDevice(_ string) (*Device, error) {
+	return &Device{Parameters: Parameters{Operate: PowerOn, TemperatureSet: s.setpoint}}, nil
+}
+
+func (s *simulatedSetpoint) SetDevice(_ string, options ...DeviceOption) error {
+	parameter := &ParameterOptions{}
+	for _, option := range options {
+		option(parameter)
+	}
+	if parameter.TemperatureSet != nil {
+		s.setpoint = *parameter.TemperatureSet
+	}
+	return nil
+}
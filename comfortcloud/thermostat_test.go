@@ -0,0 +1,114 @@
+package comfortcloud
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimulateThermostatLeavesThermostatUnchanged(t *testing.T) {
+	device := &simulatedSetpoint{setpoint: Celsius(21)}
+	room := Celsius(19)
+	strategy := &PIStrategy{Kp: 1, Ki: 0.5, IntegralLimit: 5}
+	thermostat := &Thermostat{
+		Controller: device,
+		DeviceID:   "device",
+		Source:     TemperatureSourceFunc(func(context.Context) (Temperature, error) { return room, nil }),
+		Strategy:   strategy,
+		Target:     Celsius(21),
+		Interval:   10 * time.Minute,
+		Clock:      testClock(),
+	}
+	if _, err := thermostat.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	setpoint, lastCommand, pi := *thermostat.setpoint, thermostat.lastCommand, *strategy
+
+	simulated := &FirstOrderRoom{Temperature: Celsius(15), TimeConstant: time.Hour}
+	decisions, err := SimulateThermostat(thermostat, simulated, Celsius(20), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decisions) != 12 {
+		t.Fatalf("got %d decisions, want 12", len(decisions))
+	}
+	// The simulation starts with an empty integral, so its first setpoint only has the proportional part
+	if got := decisions[0].NewSetpoint.Celsius(); got != 26 {
+		t.Errorf("first simulated setpoint %.1f, want 26", got)
+	}
+
+	if thermostat.Strategy != ControlStrategy(strategy) || *strategy != pi {
+		t.Errorf("strategy changed by simulation: %+v, want %+v", *strategy, pi)
+	}
+	if thermostat.setpoint == nil || *thermostat.setpoint != setpoint || thermostat.lastCommand != lastCommand {
+		t.Errorf("setpoint %v, last command %v changed by simulation", thermostat.setpoint, thermostat.lastCommand)
+	}
+	if thermostat.Controller != DeviceController(device) || device.setpoint != setpoint {
+		t.Errorf("device changed by simulation")
+	}
+}
+
+func TestSimulateThermostatWhileRunning(t *testing.T) {
+	device := &simulatedSetpoint{setpoint: Celsius(21)}
+	thermostat := &Thermostat{
+		Controller: device,
+		DeviceID:   "device",
+		Source:     TemperatureSourceFunc(func(context.Context) (Temperature, error) { return Celsius(19), nil }),
+		Strategy:   &PIStrategy{Kp: 1, Ki: 0.5},
+		Target:     Celsius(21),
+		Interval:   10 * time.Minute,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = thermostat.Step(context.Background())
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		simulated := &FirstOrderRoom{Temperature: Celsius(15), TimeConstant: time.Hour}
+		if _, err := SimulateThermostat(thermostat, simulated, Celsius(20), 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	if device.setpoint.Celsius() == 20 {
+		t.Error("simulated setpoint was sent to the real device")
+	}
+}
+
+func TestSimulateThermostatWithSimulatedDevice(t *testing.T) {
+	device := NewSimulatedDevice(Device{DeviceGuid: "guid-1", DeviceName: "Living room", Parameters: Parameters{
+		Operate:           PowerOn,
+		OperationMode:     OperationModeHeat,
+		FanSpeed:          FanSpeedAuto,
+		TemperatureSet:    Celsius(18),
+		InsideTemperature: Celsius(16),
+		OutTemperature:    Celsius(5),
+	}})
+	device.SensorOffset = Celsius(1.5)
+	thermostat := &Thermostat{
+		DeviceID:    "guid-1",
+		Strategy:    &HysteresisStrategy{Band: Celsius(0.25), Step: Celsius(0.5)},
+		Target:      Celsius(21),
+		MinSetpoint: Celsius(16),
+		MaxSetpoint: Celsius(30),
+		Interval:    10 * time.Minute,
+	}
+
+	decisions, err := SimulateThermostat(thermostat, device, Celsius(18), 6*12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := decisions[len(decisions)-1]
+	if room := last.Room.Celsius(); room < 20.5 || room > 21.5 {
+		t.Errorf("room temperature after 12 hours is %.2f, want about 21", room)
+	}
+	// The setpoint compensates for the unit's sensor reading warmer than the room
+	if setpoint := last.NewSetpoint.Celsius(); setpoint <= 21 {
+		t.Errorf("setpoint %.1f, want above the target", setpoint)
+	}
+	if got := device.Now().Sub(time.Unix(0, 0)); got != 12*time.Hour {
+		t.Errorf("simulated time %s, want 12h", got)
+	}
+}
@@ -1,9 +1,7 @@
 package comfortcloud
 
 import (
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -19,9 +17,11 @@ type Token struct {
 	ExpiresInSec         int    `json:"expires_in"`
 	AccClientID          string `json:"acc_client_id"`
 	Scope                string `json:"scope"`
+	AppVersion           string `json:"app_version,omitempty"`
 }
 
-func (t *Token) isValid() bool {
+// isValidAt reports whether the token is well-formed and its access token does not expire before now+leeway.
+func (t *Token) isValidAt(now time.Time, leeway time.Duration) bool {
 	if t == nil {
 		return false
 	}
@@ -33,14 +33,14 @@ func (t *Token) isValid() bool {
 		return false
 	}
 
-	expired, err := t.isAccessTokenExpired()
+	expired, err := t.isAccessTokenExpiredAt(now, leeway)
 	if err != nil {
 		return false
 	}
 	return !expired
 }
 
-func (t *Token) isAccessTokenExpired() (bool, error) {
+func (t *Token) isAccessTokenExpiredAt(now time.Time, leeway time.Duration) (bool, error) {
 	if t == nil {
 		return false, fmt.Errorf("Token is nil")
 	}
@@ -50,9 +50,7 @@ func (t *Token) isAccessTokenExpired() (bool, error) {
 			return false, fmt.Errorf("failed to set IAT and EXP: %s", err)
 		}
 	}
-	now := time.Now().Unix()
-
-	if now > t.AccessTokenExpiresAt {
+	if now.Add(leeway).Unix() > t.AccessTokenExpiresAt {
 		return true, nil
 	}
 
@@ -68,31 +66,6 @@ func (t *Token) setIATAndEXP() error {
 	return nil
 }
 
-func (t *Token) getAPIKey(timestamp time.Time) string {
-
-	normalizedTime := time.Date(
-		timestamp.Year(), timestamp.Month(), timestamp.Day(),
-		timestamp.Hour(), timestamp.Minute(), timestamp.Second(),
-		0, time.UTC) // Force UTC by leaving out timezone information
-
-	// Convert to Unix timestamp in milliseconds
-	timestampMs := fmt.Sprintf("%d", normalizedTime.UnixNano()/int64(time.Millisecond))
-
-	components := []string{
-		"Comfort Cloud",
-		"521325fb2dd486bf4831b47644317fca",
-		timestampMs,
-		"Bearer ",
-		t.AccessToken,
-	}
-
-	inputBuffer := strings.Join(components, "")
-	hash := sha256.Sum256([]byte(inputBuffer))
-	hashStr := hex.EncodeToString(hash[:])
-	result := hashStr[:9] + "cfc" + hashStr[9:]
-	return result
-}
-
 func extractIATAndEXPFromJWT(token string) (int64, int64, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) < 2 {
@@ -105,19 +78,20 @@ func extractIATAndEXPFromJWT(token string) (int64, int64, error) {
 		return 0, 0, fmt.Errorf("error decoding JWT payload: %w", err)
 	}
 
-	var payload map[string]interface{}
+	var payload struct {
+		Iat *float64 `json:"iat"`
+		Exp *float64 `json:"exp"`
+	}
 	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
 		return 0, 0, fmt.Errorf("error parsing JWT JSON: %w", err)
 	}
 
-	iat, ok := payload["iat"].(float64)
-	if !ok {
+	if payload.Iat == nil {
 		return 0, 0, fmt.Errorf("iat not found or invalid")
 	}
-	exp, ok := payload["exp"].(float64)
-	if !ok {
+	if payload.Exp == nil {
 		return 0, 0, fmt.Errorf("exp not found or invalid")
 	}
 
-	return int64(iat), int64(exp), nil
+	return int64(*payload.Iat), int64(*payload.Exp), nil
 }
@@ -0,0 +1,70 @@
+package comfortcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
+)
+
+// Auth0Error is an error response of the Panasonic ID (Auth0) token endpoint.
+type Auth0Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Auth0Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("auth0 error %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("auth0 error %d: %s", e.StatusCode, e.Code)
+}
+
+// parseAuth0Error returns an Auth0Error for an error response, or a generic error if the body
+// is not an Auth0 error.
+func parseAuth0Error(function string, statusCode int, body []byte) error {
+	auth0Error := Auth0Error{StatusCode: statusCode}
+	if err := json.Unmarshal(body, &auth0Error); err != nil || auth0Error.Code == "" {
+		return fmt.Errorf("%s: expected status 200, got %d", function, statusCode)
+	}
+	return &auth0Error
+}
+
+// parseTokenResponse decodes a response of the token endpoint for the grant type and checks that it contains
+// an access token. The response to an authorization code must also contain a refresh token, as the token
+// could not be renewed without a new login otherwise.
+func parseTokenResponse(body []byte, grantType string) (Token, error) {
+	var tokenResponse Token
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return Token{}, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResponse.AccessToken == "" {
+		return Token{}, errors.New("invalid token response: access_token is missing")
+	}
+	if grantType == grantTypeAuthorizationCode && tokenResponse.RefreshToken == "" {
+		return Token{}, errors.New("invalid token response: refresh_token is missing")
+	}
+	if tokenResponse.ExpiresInSec < 0 {
+		return Token{}, fmt.Errorf("invalid token response: expires_in is %d", tokenResponse.ExpiresInSec)
+	}
+	return tokenResponse, nil
+}
+
+// parseAccClientIDResponse decodes the response of the Comfort Cloud login and returns the ACC client ID.
+func parseAccClientIDResponse(body []byte) (string, error) {
+	var accClientResponse struct {
+		ClientID string `json:"clientId"`
+	}
+	if err := json.Unmarshal(body, &accClientResponse); err != nil {
+		return "", fmt.Errorf("failed to decode ACC login response: %w", err)
+	}
+	if accClientResponse.ClientID == "" {
+		return "", errors.New("invalid ACC login response: clientId is missing")
+	}
+	return accClientResponse.ClientID, nil
+}
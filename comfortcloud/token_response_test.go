@@ -0,0 +1,79 @@
+package comfortcloud
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTokenResponseRequiresRefreshToken(t *testing.T) {
+	body := []byte(`{"access_token":"a.b.c","expires_in":86400}`)
+	if _, err := parseTokenResponse(body, grantTypeAuthorizationCode); err == nil {
+		t.Error("authorization code response without refresh_token accepted")
+	}
+	if _, err := parseTokenResponse(body, grantTypeRefreshToken); err != nil {
+		t.Errorf("refresh response without refresh_token rejected: %v", err)
+	}
+}
+
+func FuzzParseTokenResponse(f *testing.F) {
+	f.Add([]byte(`{"access_token":"a.b.c","refresh_token":"r","expires_in":86400,"scope":"openid"}`))
+	f.Add([]byte(`{"access_token":"a.b.c"}`))
+	f.Add([]byte(`{"access_token":"","refresh_token":"r"}`))
+	f.Add([]byte(`{"access_token":"a.b.c","refresh_token":"r","expires_in":-1}`))
+	f.Add([]byte(`{"access_token":1}`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(``))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, grantType := range []string{grantTypeAuthorizationCode, grantTypeRefreshToken} {
+			token, err := parseTokenResponse(body, grantType)
+			if err != nil {
+				continue
+			}
+			if token.AccessToken == "" {
+				t.Errorf("%s: accepted token response without access_token: %q", grantType, body)
+			}
+			if token.ExpiresInSec < 0 {
+				t.Errorf("%s: accepted negative expires_in: %q", grantType, body)
+			}
+			if grantType == grantTypeAuthorizationCode && token.RefreshToken == "" {
+				t.Errorf("%s: accepted token response without refresh_token: %q", grantType, body)
+			}
+		}
+	})
+}
+
+func FuzzParseAccClientIDResponse(f *testing.F) {
+	f.Add([]byte(`{"clientId":"abc"}`))
+	f.Add([]byte(`{"clientId":""}`))
+	f.Add([]byte(`{"clientId":null}`))
+	f.Add([]byte(`{"message":"error","code":4100}`))
+	f.Add([]byte(`"abc"`))
+	f.Add([]byte(``))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		clientID, err := parseAccClientIDResponse(body)
+		if err == nil && clientID == "" {
+			t.Errorf("accepted ACC login response without clientId: %q", body)
+		}
+	})
+}
+
+func FuzzParseAuth0Error(f *testing.F) {
+	f.Add(403, []byte(`{"error":"invalid_grant","error_description":"Unknown or invalid refresh token."}`))
+	f.Add(429, []byte(`{"error":"too_many_attempts"}`))
+	f.Add(500, []byte(`{"error":""}`))
+	f.Add(502, []byte(`<html>Bad Gateway</html>`))
+	f.Add(0, []byte(``))
+	f.Add(403, []byte(`{"error":"e","statusCode":7}`))
+	f.Add(401, []byte(`{"error":"e","StatusCode":7}`))
+	f.Fuzz(func(t *testing.T, statusCode int, body []byte) {
+		err := parseAuth0Error("get_token", statusCode, body)
+		if err == nil {
+			t.Fatalf("no error for status %d and body %q", statusCode, body)
+		}
+		var auth0Error *Auth0Error
+		if errors.As(err, &auth0Error) && (auth0Error.Code == "" || auth0Error.StatusCode != statusCode) {
+			t.Errorf("invalid Auth0Error %+v for status %d and body %q", auth0Error, statusCode, body)
+		}
+	})
+}
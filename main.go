@@ -1,28 +1,50 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/seb-ehm/panasonic-comfort-cloud/comfortcloud"
 	"log"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 )
 
+const defaultAuditLog = ".panasonic-audit.jsonl"
+
 func main() {
+	// The settings may also come from the environment, e.g. for the audit command
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	username := os.Getenv("PANASONIC_USER")
-	if username == "" {
-		log.Fatal("PANASONIC_USER is not set in the .env file")
+	auditFile := os.Getenv("PANASONIC_AUDIT_LOG")
+	if auditFile == "" {
+		auditFile = defaultAuditLog
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "audit":
+			if err := runAudit(auditFile, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			log.Fatalf("Unknown command %q, available commands: audit", os.Args[1])
+		}
+		return
 	}
 
+	// Without PANASONIC_USER and PANASONIC_PASSWORD, the login is completed in a browser
+	// and only the token is stored.
+	username := os.Getenv("PANASONIC_USER")
 	password := os.Getenv("PANASONIC_PASSWORD")
-	if password == "" {
-		log.Fatal("PANASONIC_PASSWORD is not set in the .env file")
-	}
 
 	deviceID := os.Getenv("PANASONIC_DEVICE_ID")
 	if deviceID == "" {
@@ -36,23 +58,169 @@ func main() {
 	//fmt.Println("Refreshing token")
 	//err = auth.RefreshToken()
 
-	c := comfortcloud.NewClient(username, password, ".panasonic-oauth-token")
-	c.Login()
-	err = c.FetchGroupsAndDevices()
+	options := []comfortcloud.ClientOption{
+		comfortcloud.WithDeviceCacheFile(".panasonic-devices"),
+		comfortcloud.WithAuditLog(comfortcloud.NewAuditLog(auditFile)),
+		comfortcloud.WithAuthOptions(comfortcloud.WithChallengeHandler(promptChallenge)),
+	}
+	// With PANASONIC_DRY_RUN set, commands are only planned and never sent to the units.
+	if os.Getenv("PANASONIC_DRY_RUN") != "" {
+		options = append(options, comfortcloud.WithDryRun(), comfortcloud.WithPlanListener(func(plan *comfortcloud.Plan) {
+			fmt.Println("Plan:", plan)
+			fmt.Println("Payload:", string(plan.Payload))
+		}))
+	}
+	c := comfortcloud.NewClient(username, password, ".panasonic-oauth-token", options...)
+	if aliasFile := os.Getenv("PANASONIC_ALIAS_FILE"); aliasFile != "" {
+		if err := c.LoadAliasFile(aliasFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = c.Login()
+	if errors.Is(err, comfortcloud.ErrLoginRequired) {
+		err = browserLogin(c)
+	}
 	if err != nil {
-		fmt.Println(err)
+		log.Fatal(err)
 	}
-	device, err := c.GetDevice("")
+	device, err := c.GetDevice(deviceID)
 	if err != nil {
 		fmt.Println(err)
 	}
 	fmt.Println(device)
 	fmt.Println("#######")
 
-	err = c.SetDevice(deviceID,
+	if historyDB := os.Getenv("PANASONIC_HISTORY_DB"); historyDB != "" && device != nil {
+		if err := recordHistory(c, historyDB, device.DeviceGuid); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	command := []comfortcloud.DeviceOption{
 		comfortcloud.WithPower(comfortcloud.PowerOn),
-		comfortcloud.WithTemperature(22.0))
+		comfortcloud.WithTemperature(comfortcloud.Celsius(22)),
+	}
+	ctx := comfortcloud.WithCaller(context.Background(), caller())
+	err = c.SetDeviceContext(ctx, deviceID, command...)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// promptChallenge asks on the terminal for the answer to an interstitial login page.
+func promptChallenge(challenge comfortcloud.Challenge) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	switch challenge.Kind {
+	case comfortcloud.InterstitialMFA:
+		fmt.Print("Enter one-time password: ")
+	default:
+		fmt.Printf("Login requires accepting the %s page (%s). Accept? [y/N]: ", challenge.Kind, challenge.URL)
+	}
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if challenge.Kind != comfortcloud.InterstitialMFA && !strings.EqualFold(answer, "y") {
+		return "", nil
+	}
+	return answer, nil
+}
+
+// browserLogin lets the user log in with a browser and paste the URL they are redirected to.
+func browserLogin(c *comfortcloud.Client) error {
+	login := c.BeginBrowserLogin()
+	fmt.Println("Open the following URL in a browser and log in:")
+	fmt.Println(login.AuthorizeURL)
+	fmt.Println("The browser then fails to open a panasonic-iot-cfc:// URL. Copy it from the address bar " +
+		"or the developer tools network tab and paste it here:")
+	redirectURL, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	return c.CompleteBrowserLogin(login, strings.TrimSpace(redirectURL))
+}
+
+// recordHistory stores the current status and history of all devices in the local history database and
+// prints the inside temperature of the device over the last day.
+func recordHistory(c *comfortcloud.Client, fileName, deviceGuid string) error {
+	store, err := comfortcloud.OpenHistoryStore(fileName)
 	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	recorder := &comfortcloud.Recorder{
+		Client:       c,
+		Store:        store,
+		HistoryModes: []comfortcloud.DataMode{comfortcloud.DataModeDay},
+		Retention:    comfortcloud.RetentionPolicy{Snapshots: 90 * 24 * time.Hour},
+	}
+	if err := recorder.RecordOnce(ctx); err != nil {
 		fmt.Println(err)
 	}
+	samples, err := store.QuerySamples(ctx, comfortcloud.SampleQuery{
+		DeviceGuid: deviceGuid,
+		Metric:     comfortcloud.MetricInsideTemperature,
+		From:       time.Now().Add(-24 * time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		fmt.Printf("%s %.1f°C\n", sample.Time.Format(time.DateTime), sample.Value)
+	}
+	return nil
+}
+
+// caller identifies the user in the audit log.
+func caller() string {
+	if name := os.Getenv("PANASONIC_CALLER"); name != "" {
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// runAudit prints the entries of the audit log matching the command line flags.
+func runAudit(fileName string, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	device := flags.String("device", "", "only show commands to the device with this GUID or name")
+	callerName := flags.String("caller", "", "only show commands of this caller")
+	outcome := flags.String("outcome", "", "only show commands with this outcome")
+	since := flags.Duration("since", 0, "only show commands of the last duration, e.g. 24h")
+	asJSON := flags.Bool("json", false, "print the entries as JSON Lines")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query := comfortcloud.AuditQuery{Device: *device, Caller: *callerName, Outcome: *outcome}
+	if *since > 0 {
+		query.From = time.Now().Add(-*since)
+	}
+	entries, err := comfortcloud.QueryAuditLog(fileName, query)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tCALLER\tDEVICE\tOUTCOME\tOPTIONS\tERROR")
+	for _, entry := range entries {
+		options, err := json.Marshal(entry.Options)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format(time.DateTime), entry.Caller,
+			entry.DeviceName, entry.Outcome, options, entry.Error)
+	}
+	return writer.Flush()
 }